@@ -0,0 +1,190 @@
+// Package attach reads local files and directories into message parts so
+// their contents can be sent to the model alongside a prompt.
+package attach
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergey-suslov/ai-notes/store"
+	"github.com/sergey-suslov/ai-notes/util"
+)
+
+const (
+	// DefaultChunkTokens is the largest chunk a single file part may hold.
+	DefaultChunkTokens = 2000
+	// DefaultMaxTokens is the total budget for all attachments of one message.
+	DefaultMaxTokens = 32000
+
+	// sniffLen is how many leading bytes are inspected to detect binary files.
+	sniffLen = 8000
+)
+
+// refPattern matches @path references in a prompt.
+var refPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// Load reads every path (file or directory) and returns one or more file parts
+// per text file. Directories are walked recursively, honoring .gitignore files
+// and skipping binary files. Files larger than chunkTokens are split into
+// several parts; an error is returned if all parts together, including the
+// staged parts already attached to the same message, exceed maxTokens.
+func Load(paths []string, staged []store.Part, chunkTokens, maxTokens int) ([]store.Part, error) {
+	var parts []store.Part
+	used := Tokens(staged)
+	add := func(name string, data []byte) error {
+		chunks := util.SplitTokens(string(data), chunkTokens)
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		for i, c := range chunks {
+			used += util.EstimateTokens(c)
+			if used > maxTokens {
+				return fmt.Errorf("attachments exceed token budget of %d at %s", maxTokens, name)
+			}
+			p := store.Part{Type: store.PartFile, Filename: name, Hash: hash, Text: c}
+			if len(chunks) > 1 {
+				p.Chunk = i + 1
+				p.Chunks = len(chunks)
+			}
+			parts = append(parts, p)
+		}
+		return nil
+	}
+	for _, p := range paths {
+		p = ExpandHome(p)
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading attachment: %w", err)
+		}
+		if !info.IsDir() {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("reading attachment %s: %w", p, err)
+			}
+			if !isText(data) {
+				return nil, fmt.Errorf("attachment %s is not a text file", p)
+			}
+			if err := add(filepath.Clean(p), data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := walkDir(p, add); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+// walkDir calls add for every text file under root that is not ignored.
+func walkDir(root string, add func(name string, data []byte) error) error {
+	var ig ignoreMatcher
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == "." {
+				return ig.load(p, "")
+			}
+			if d.Name() == ".git" || ig.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			return ig.load(p, rel)
+		}
+		if !d.Type().IsRegular() || ig.ignored(rel, false) {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading attachment %s: %w", p, err)
+		}
+		if !isText(data) {
+			return nil
+		}
+		return add(filepath.Join(root, filepath.FromSlash(rel)), data)
+	})
+}
+
+// IsImage reports whether path names an image that can be sent to a
+// vision-capable model rather than read as text.
+func IsImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// References returns the @path references in text that point to existing
+// files or directories.
+func References(text string) []string {
+	var paths []string
+	for _, m := range refPattern.FindAllStringSubmatch(text, -1) {
+		p := strings.TrimRight(m[1], ".,;:!?)")
+		if _, err := os.Stat(ExpandHome(p)); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Tokens estimates the tokens the file parts of parts add to a message.
+func Tokens(parts []store.Part) int {
+	n := 0
+	for _, p := range parts {
+		if p.Type == store.PartFile {
+			n += util.EstimateTokens(p.Text)
+		}
+	}
+	return n
+}
+
+// Format renders parts as text suitable for appending to a prompt.
+func Format(parts []store.Part) string {
+	var b strings.Builder
+	for _, p := range parts {
+		if p.Type != store.PartFile {
+			continue
+		}
+		name := p.Filename
+		if p.Chunks > 1 {
+			name = fmt.Sprintf("%s (part %d/%d)", p.Filename, p.Chunk, p.Chunks)
+		}
+		fmt.Fprintf(&b, "\n\nFile: %s\n```\n%s\n```", name, strings.TrimRight(p.Text, "\n"))
+	}
+	return b.String()
+}
+
+// isText reports whether data looks like UTF-8 text.
+func isText(data []byte) bool {
+	head := data
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	return !bytes.ContainsRune(head, 0) && utf8.Valid(data)
+}
+
+// ExpandHome replaces a leading "~/" with the user's home directory.
+func ExpandHome(p string) string {
+	if !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[2:])
+}
@@ -0,0 +1,114 @@
+package attach
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single parsed line of a .gitignore file.
+type ignoreRule struct {
+	base     string   // slash-separated directory of the .gitignore, relative to the walk root
+	segments []string // pattern split on "/"
+	negate   bool     // pattern started with "!"
+	dirOnly  bool     // pattern ended with "/"
+	anchored bool     // pattern contained a "/" before its last character
+}
+
+// ignoreMatcher holds the rules collected from every .gitignore seen during a walk.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// load reads the .gitignore in dir (if any) and appends its rules.
+// rel is dir relative to the walk root, slash-separated ("" for the root).
+func (m *ignoreMatcher) load(dir, rel string) error {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: rel}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.segments = strings.Split(line, "/")
+		m.rules = append(m.rules, r)
+	}
+	return sc.Err()
+}
+
+// ignored reports whether rel (slash-separated, relative to the walk root) is
+// excluded. Later rules override earlier ones, as in git.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		p := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			p = strings.TrimPrefix(rel, r.base+"/")
+		}
+		var ok bool
+		if r.anchored {
+			ok = matchSegments(r.segments, strings.Split(p, "/"))
+		} else {
+			ok, _ = path.Match(r.segments[0], path.Base(p))
+		}
+		if ok {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more whole segments.
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segs[1:])
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+   "encoding/json"
+   "errors"
+   "flag"
+   "fmt"
+   "os"
+   "text/tabwriter"
+   "time"
+
+   openaiclient "github.com/sergey-suslov/ai-notes/openai"
+)
+
+// runAudit implements "ai-notes audit": it lists the requests recorded in
+// the audit log, optionally limited to a date range and a session.
+func runAudit(args []string) error {
+   fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+   from := fs.String("from", "", "first day to list, YYYY-MM-DD")
+   to := fs.String("to", "", "last day to list, YYYY-MM-DD")
+   session := fs.String("session", "", "only list requests of this session ID")
+   asJSON := fs.Bool("json", false, "print the matching entries as JSONL")
+   fs.Usage = func() {
+       fmt.Fprintf(fs.Output(), "usage: ai-notes audit [-from DAY] [-to DAY] [-session ID] [-json]\n\n")
+       fs.PrintDefaults()
+   }
+   if err := fs.Parse(args); err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           return nil
+       }
+       return err
+   }
+   filter := openaiclient.AuditFilter{Session: *session}
+   var err error
+   if filter.From, err = parseDay(*from); err != nil {
+       return err
+   }
+   if filter.To, err = parseDay(*to); err != nil {
+       return err
+   }
+   if !filter.To.IsZero() {
+       // the last day is included
+       filter.To = filter.To.AddDate(0, 0, 1)
+   }
+   path, err := openaiclient.AuditPath()
+   if err != nil {
+       return err
+   }
+   entries, err := openaiclient.ReadAudit(path, filter)
+   if err != nil {
+       return err
+   }
+   if *asJSON {
+       enc := json.NewEncoder(os.Stdout)
+       for _, e := range entries {
+           if err := enc.Encode(e); err != nil {
+               return err
+           }
+       }
+       return nil
+   }
+   w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+   fmt.Fprintln(w, "TIME\tSESSION\tENDPOINT\tMODEL\tSTATUS\tTOKENS\tPAYLOAD")
+   for _, e := range entries {
+       tokens := "-"
+       if e.Usage != nil {
+           tokens = fmt.Sprintf("%d+%d", e.Usage.PromptTokens, e.Usage.CompletionTokens)
+       }
+       hash := e.PayloadHash
+       if len(hash) > 12 {
+           hash = hash[:12]
+       }
+       status := e.Status
+       if e.Error != "" {
+           status += ": " + e.Error
+       }
+       fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"),
+           e.Session, e.Endpoint, e.Model, status, tokens, hash)
+   }
+   return w.Flush()
+}
+
+// parseDay parses a YYYY-MM-DD day in local time; "" is the zero time.
+func parseDay(s string) (time.Time, error) {
+   if s == "" {
+       return time.Time{}, nil
+   }
+   t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+   if err != nil {
+       return time.Time{}, fmt.Errorf("invalid day %q, expected YYYY-MM-DD", s)
+   }
+   return t, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+   "fmt"
+   "os"
+   "text/tabwriter"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   openaiclient "github.com/sergey-suslov/ai-notes/openai"
+)
+
+// printBudgets implements "ai-notes budget": it lists the configured budgets
+// with what has been spent of them in the current day or month.
+func printBudgets() error {
+   cfg, err := config.Load()
+   if err != nil {
+       return fmt.Errorf("loading config: %w", err)
+   }
+   if len(cfg.Budgets) == 0 {
+       fmt.Println("no budgets configured")
+       return nil
+   }
+   for _, b := range cfg.Budgets {
+       if err := b.Validate(); err != nil {
+           return err
+       }
+   }
+   path, err := openaiclient.SpendPath()
+   if err != nil {
+       return err
+   }
+   status, err := openaiclient.ReadBudgets(cfg.Budgets, path)
+   if err != nil {
+       return err
+   }
+   w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+   fmt.Fprintln(w, "BUDGET\tSPENT\tUSED")
+   for _, s := range status {
+       fmt.Fprintf(w, "%s\t%s\t%.0f%%\n", s.Budget, s.Budget.Format(s.Spent), 100*s.Spent/s.Budget.Limit)
+   }
+   return w.Flush()
+}
@@ -0,0 +1,190 @@
+// Package config loads user settings from ~/.ai-notes/config.json.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	baseDirName    = ".ai-notes"
+	configFileName = "config.json"
+
+	// DefaultTrashRetentionDays is how long trashed items are kept by default.
+	DefaultTrashRetentionDays = 30
+)
+
+// Config holds the user's settings. Missing keys keep their defaults.
+type Config struct {
+	// TrashRetentionDays is how long deleted sessions and notes stay in the
+	// trash before they are purged automatically. Zero or less keeps them
+	// until they are deleted by hand.
+	TrashRetentionDays int `json:"trash_retention_days"`
+	// Vaults are folders of markdown files, such as Obsidian or Logseq
+	// vaults, whose files are listed as read-only notes.
+	Vaults []string `json:"vaults,omitempty"`
+	// Redact controls what is masked in messages sent to the provider.
+	Redact Redact `json:"redact"`
+	// Audit controls the log of requests sent to the provider.
+	Audit Audit `json:"audit"`
+	// Budgets limit what requests may spend per day or month.
+	Budgets []Budget `json:"budgets,omitempty"`
+	// Tools controls the tools the chat assistant may call.
+	Tools Tools `json:"tools"`
+}
+
+// Budget periods and units.
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+
+	UnitUSD    = "usd"
+	UnitTokens = "tokens"
+
+	// DefaultBudgetWarnAt is the share of a budget that triggers a warning.
+	DefaultBudgetWarnAt = 0.8
+)
+
+// Budget limits the spending of one period. Provider and Model restrict it
+// to some requests; "" matches all of them.
+type Budget struct {
+	Period   string  `json:"period"`         // PeriodDaily or PeriodMonthly
+	Limit    float64 `json:"limit"`          // in Unit
+	Unit     string  `json:"unit,omitempty"` // UnitUSD (default) or UnitTokens
+	Provider string  `json:"provider,omitempty"`
+	Model    string  `json:"model,omitempty"`
+	// WarnAt is the share of Limit after which a warning is shown,
+	// DefaultBudgetWarnAt if 0.
+	WarnAt float64 `json:"warn_at,omitempty"`
+}
+
+// String describes the budget, e.g. "daily budget of $5.00 for gpt-4o".
+func (b Budget) String() string {
+	s := fmt.Sprintf("%s budget of %s", b.Period, b.Format(b.Limit))
+	if b.Provider != "" || b.Model != "" {
+		s += " for " + strings.TrimSpace(b.Provider+" "+b.Model)
+	}
+	return s
+}
+
+// Format renders an amount in the budget's unit.
+func (b Budget) Format(amount float64) string {
+	if b.Unit == UnitTokens {
+		return fmt.Sprintf("%.0f tokens", amount)
+	}
+	return fmt.Sprintf("$%.2f", amount)
+}
+
+// Validate reports a budget the config cannot mean.
+func (b Budget) Validate() error {
+	if b.Period != PeriodDaily && b.Period != PeriodMonthly {
+		return fmt.Errorf("budget period %q: want %q or %q", b.Period, PeriodDaily, PeriodMonthly)
+	}
+	if b.Unit != "" && b.Unit != UnitUSD && b.Unit != UnitTokens {
+		return fmt.Errorf("budget unit %q: want %q or %q", b.Unit, UnitUSD, UnitTokens)
+	}
+	if b.Limit <= 0 {
+		return fmt.Errorf("%s: limit must be positive", b)
+	}
+	return nil
+}
+
+// Audit configures the audit log (~/.ai-notes/audit.jsonl).
+type Audit struct {
+	// Disabled turns the audit log off.
+	Disabled bool `json:"disabled,omitempty"`
+	// FullPayload logs whole requests instead of their sha256 hash. The
+	// log itself is not encrypted, so only hashes are logged while the
+	// store is encrypted, whatever this says.
+	FullPayload bool `json:"full_payload,omitempty"`
+}
+
+// Tools configures the tools offered to the chat assistant. They are off
+// unless enabled.
+type Tools struct {
+	// Enabled offers the tools to the assistant.
+	Enabled bool `json:"enabled,omitempty"`
+	// ReadDirs are the only directories the read_file tool may read from.
+	// read_file is not offered if there are none.
+	ReadDirs []string `json:"read_dirs,omitempty"`
+}
+
+// Redact configures the redaction of secrets and personal data.
+type Redact struct {
+	// Disabled turns redaction off.
+	Disabled bool `json:"disabled,omitempty"`
+	// Skip lists built-in rules to leave out: private_key, api_key, token,
+	// email or phone.
+	Skip []string `json:"skip,omitempty"`
+	// Patterns are extra regular expressions to redact, keyed by the name
+	// used in their placeholders.
+	Patterns map[string]string `json:"patterns,omitempty"`
+}
+
+// Default returns the settings used when no config file exists.
+func Default() *Config {
+	return &Config{
+		TrashRetentionDays: DefaultTrashRetentionDays,
+	}
+}
+
+// Path returns the location of the config file (~/.ai-notes/config.json).
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %w", err)
+	}
+	return filepath.Join(home, baseDirName, configFileName), nil
+}
+
+// Load reads the config file, returning the defaults if it does not exist.
+func Load() (*Config, error) {
+	cfg := Default()
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config JSON: %w", err)
+	}
+	return cfg, nil
+}
+
+// Save writes the config file.
+func (c *Config) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating config dir: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding config JSON: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	return nil
+}
+
+// TrashRetention returns the trash retention period as a duration, or 0
+// if trashed items are never purged.
+func (c *Config) TrashRetention() time.Duration {
+	if c.TrashRetentionDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.TrashRetentionDays) * 24 * time.Hour
+}
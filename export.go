@@ -0,0 +1,103 @@
+package main
+
+import (
+   "errors"
+   "flag"
+   "fmt"
+   "os"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   "github.com/sergey-suslov/ai-notes/export"
+   "github.com/sergey-suslov/ai-notes/store"
+)
+
+// runExport implements "ai-notes export": it writes the given sessions or
+// notes, or all of them if no IDs are given, to files in one format.
+func runExport(args []string) error {
+   fs := flag.NewFlagSet("export", flag.ContinueOnError)
+   format := fs.String("format", export.DefaultFormat, "output format")
+   dir := fs.String("o", ".", "output directory")
+   notebook := fs.String("notebook", "", "only export notes in this notebook")
+   fs.Usage = func() {
+       fmt.Fprintf(fs.Output(), "usage: ai-notes export [flags] sessions|notes [ID...]\n\nformats: %v\n\n", export.Names())
+       fs.PrintDefaults()
+   }
+   if err := fs.Parse(args); err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           return nil
+       }
+       return err
+   }
+   if fs.NArg() < 1 {
+       fs.Usage()
+       return fmt.Errorf("missing what to export")
+   }
+   f, err := export.Lookup(*format)
+   if err != nil {
+       return err
+   }
+   ids := make(map[string]bool)
+   for _, id := range fs.Args()[1:] {
+       ids[id] = true
+   }
+   var paths []string
+   switch fs.Arg(0) {
+   case "sessions", "session":
+       sessions, err := store.LoadSessions()
+       if err != nil {
+           return fmt.Errorf("loading sessions: %w", err)
+       }
+       var selected []*store.Session
+       for _, s := range sessions {
+           if len(ids) == 0 || ids[s.ID] {
+               selected = append(selected, s)
+               delete(ids, s.ID)
+           }
+       }
+       if err := missing(ids); err != nil {
+           return err
+       }
+       paths, err = export.Sessions(*dir, f, selected)
+       if err != nil {
+           return err
+       }
+   case "notes", "note":
+       cfg, err := config.Load()
+       if err != nil {
+           return fmt.Errorf("loading config: %w", err)
+       }
+       notes, err := store.LoadAllNotes(cfg.Vaults)
+       if err != nil {
+           return fmt.Errorf("loading notes: %w", err)
+       }
+       var selected []*store.Note
+       for _, n := range store.FilterNotebook(notes, store.NormalizeNotebook(*notebook)) {
+           if len(ids) == 0 || ids[n.ID] {
+               selected = append(selected, n)
+               delete(ids, n.ID)
+           }
+       }
+       if err := missing(ids); err != nil {
+           return err
+       }
+       paths, err = export.Notes(*dir, f, selected)
+       if err != nil {
+           return err
+       }
+   default:
+       return fmt.Errorf("cannot export %q: use sessions or notes", fs.Arg(0))
+   }
+   for _, p := range paths {
+       fmt.Println(p)
+   }
+   fmt.Fprintf(os.Stderr, "exported %d files\n", len(paths))
+   return nil
+}
+
+// missing reports IDs that were asked for but not found.
+func missing(ids map[string]bool) error {
+   for id := range ids {
+       return fmt.Errorf("%s not found", id)
+   }
+   return nil
+}
@@ -0,0 +1,189 @@
+// Package export writes sessions and notes to files in pluggable formats.
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// DefaultFormat is the format used when none is given.
+const DefaultFormat = "markdown"
+
+// Format renders sessions and notes in one output format.
+type Format interface {
+	// Ext is the file extension, including the dot.
+	Ext() string
+	Session(s *store.Session) ([]byte, error)
+	Note(n *store.Note) ([]byte, error)
+}
+
+var formats = map[string]Format{}
+
+// Register makes a format available under name.
+func Register(name string, f Format) {
+	formats[name] = f
+}
+
+// Lookup returns the format registered under name.
+func Lookup(name string) (Format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return f, nil
+}
+
+// Names lists the registered formats in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("markdown", markdownFormat{})
+	Register("html", htmlFormat{})
+	Register("jsonl", jsonlFormat{})
+	Register("org", orgFormat{})
+}
+
+// Sessions writes each session to its own file in dir and returns the paths.
+func Sessions(dir string, f Format, sessions []*store.Session) ([]string, error) {
+	var paths []string
+	for _, s := range sessions {
+		data, err := f.Session(s)
+		if err != nil {
+			return paths, fmt.Errorf("exporting session %s: %w", s.ID, err)
+		}
+		path, err := write(dir, s.ID, s.DisplayTitle(), f.Ext(), data)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Notes writes each note to its own file in dir and returns the paths.
+func Notes(dir string, f Format, notes []*store.Note) ([]string, error) {
+	var paths []string
+	for _, n := range notes {
+		data, err := f.Note(n)
+		if err != nil {
+			return paths, fmt.Errorf("exporting note %s: %w", n.ID, err)
+		}
+		path, err := write(dir, n.ID, n.Title, f.Ext(), data)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// write stores data in dir as "<id>-<slug of title><ext>".
+func write(dir, id, title, ext string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating export dir: %w", err)
+	}
+	name := id
+	if s := slug(title); s != "" && s != strings.ToLower(id) {
+		name += "-" + s
+	}
+	path := filepath.Join(dir, name+ext)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("writing export file: %w", err)
+	}
+	return path, nil
+}
+
+// slug turns a title into a lowercase, dash-separated file name part.
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	return b.String()
+}
+
+// roleName is the heading used for a message role.
+func roleName(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "system":
+		return "System"
+	}
+	if role == "" {
+		return "Message"
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
+
+// attachmentList describes the files and images attached to a message.
+func attachmentList(parts []store.Part) []string {
+	var out []string
+	for _, p := range parts {
+		name := p.Filename
+		if p.Chunks > 1 {
+			name = fmt.Sprintf("%s (part %d/%d)", name, p.Chunk, p.Chunks)
+		}
+		if p.Type == store.PartImage {
+			name += fmt.Sprintf(" (image, %dx%d)", p.Width, p.Height)
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// noteMeta lists the note's metadata as label/value pairs.
+func noteMeta(n *store.Note) [][2]string {
+	var meta [][2]string
+	add := func(label, value string) {
+		if value != "" {
+			meta = append(meta, [2]string{label, value})
+		}
+	}
+	if !n.CreatedAt.IsZero() {
+		add("Created", n.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	add("Session", n.SessionID)
+	add("Notebook", n.Notebook)
+	if len(n.Tags) > 0 {
+		add("Tags", "#"+strings.Join(n.Tags, " #"))
+	}
+	add("Source", n.SourceRange())
+	return meta
+}
+
+// DefaultDir returns the directory the TUI exports to (~/.ai-notes/exports).
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".ai-notes", "exports"), nil
+}
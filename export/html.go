@@ -0,0 +1,183 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/sergey-suslov/ai-notes/store"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// codeStyle is the chroma style used for code blocks.
+const codeStyle = "github"
+
+// htmlFormat writes standalone HTML pages with embedded styles and
+// syntax-highlighted code blocks.
+type htmlFormat struct{}
+
+func (htmlFormat) Ext() string { return ".html" }
+
+// htmlMessage is a rendered chat message.
+type htmlMessage struct {
+	Role, Class string
+	Body        template.HTML
+	Attachments []string
+}
+
+// htmlPage is the data of pageTemplate.
+type htmlPage struct {
+	Title, Subtitle string
+	CodeCSS         template.CSS
+	Meta            [][2]string
+	Messages        []htmlMessage
+	Body            template.HTML
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.5; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5em; }
+.subtitle, .meta { color: #656d76; }
+.meta dt { font-weight: bold; float: left; margin-right: .5em; }
+.msg { border-radius: 8px; padding: .2em 1em; margin: 1em 0; }
+.msg h2 { font-size: .9em; text-transform: uppercase; color: #656d76; margin: .5em 0; }
+.user { background: #ddf4ff; }
+.assistant { background: #f6f8fa; }
+.system { background: #fff8c5; }
+.plain { white-space: pre-wrap; }
+.attachments { font-size: .9em; color: #656d76; }
+pre { padding: .8em; overflow-x: auto; border-radius: 6px; background: #f6f8fa; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: .9em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: .3em .6em; }
+{{.CodeCSS}}
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+{{if .Subtitle}}<p class="subtitle">{{.Subtitle}}</p>{{end}}
+{{if .Meta}}<dl class="meta">{{range .Meta}}<dt>{{index . 0}}</dt><dd>{{index . 1}}</dd>{{end}}</dl>{{end}}
+</header>
+{{range .Messages}}<section class="msg {{.Class}}">
+<h2>{{.Role}}</h2>
+{{.Body}}
+{{if .Attachments}}<p class="attachments">Attachments: {{range $i, $a := .Attachments}}{{if $i}}, {{end}}{{$a}}{{end}}</p>{{end}}
+</section>
+{{end}}{{.Body}}
+</body>
+</html>
+`))
+
+// Session renders the transcript with a styled section per message.
+func (htmlFormat) Session(s *store.Session) ([]byte, error) {
+	page := htmlPage{
+		Title:    s.DisplayTitle(),
+		Subtitle: s.Description,
+		Meta:     [][2]string{{"Created", s.CreatedAt.Format("2006-01-02 15:04")}, {"Messages", fmt.Sprint(len(s.Chat))}},
+	}
+	for _, msg := range s.Chat {
+		// user messages are typed as plain text, replies are markdown
+		body := template.HTML(`<p class="plain">` + template.HTMLEscapeString(msg.Content) + `</p>`)
+		if msg.Role != "user" {
+			var err error
+			if body, err = markdownToHTML(msg.Content); err != nil {
+				return nil, err
+			}
+		}
+		page.Messages = append(page.Messages, htmlMessage{
+			Role: roleName(msg.Role), Class: msg.Role, Body: body, Attachments: attachmentList(msg.Parts),
+		})
+	}
+	return renderPage(page)
+}
+
+// Note renders the note with its metadata.
+func (htmlFormat) Note(n *store.Note) ([]byte, error) {
+	body, err := markdownToHTML(n.Markdown())
+	if err != nil {
+		return nil, err
+	}
+	return renderPage(htmlPage{Title: n.Title, Meta: noteMeta(n), Body: body})
+}
+
+// renderPage executes pageTemplate with the code highlighting stylesheet.
+func renderPage(page htmlPage) ([]byte, error) {
+	var css bytes.Buffer
+	if err := chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(&css, styles.Get(codeStyle)); err != nil {
+		return nil, err
+	}
+	page.CodeCSS = template.CSS(css.String())
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// markdownToHTML renders markdown with GitHub extensions. Raw HTML in the
+// input is escaped and fenced code is highlighted with chroma.
+func markdownToHTML(md string) (template.HTML, error) {
+	gm := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(renderer.WithNodeRenderers(util.Prioritized(codeRenderer{}, 100))),
+	)
+	var buf bytes.Buffer
+	if err := gm.Convert([]byte(md), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// codeRenderer renders fenced code blocks with chroma.
+type codeRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (codeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderCode)
+}
+
+// renderCode writes a fenced code block as highlighted HTML, guessing the
+// language when the fence does not name one.
+func renderCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.FencedCodeBlock)
+	var code strings.Builder
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		code.Write(seg.Value(source))
+	}
+	lexer := lexers.Get(string(block.Language(source)))
+	if lexer == nil {
+		lexer = lexers.Analyse(code.String())
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	if err := chromahtml.New(chromahtml.WithClasses(true)).Format(w, styles.Get(codeStyle), it); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
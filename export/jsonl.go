@@ -0,0 +1,83 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// jsonlFormat writes one JSON object per line: a session header followed by
+// its messages, or a single line per note.
+type jsonlFormat struct{}
+
+func (jsonlFormat) Ext() string { return ".jsonl" }
+
+// jsonlSession is the first line of an exported session.
+type jsonlSession struct {
+	Type        string      `json:"type"`
+	ID          string      `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	Usage       store.Usage `json:"usage"`
+}
+
+// jsonlMessage is a line per message of an exported session.
+type jsonlMessage struct {
+	Type        string   `json:"type"`
+	SessionID   string   `json:"session_id"`
+	Index       int      `json:"index"`
+	Role        string   `json:"role"`
+	Content     string   `json:"content"`
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// jsonlNote is the line of an exported note.
+type jsonlNote struct {
+	Type          string             `json:"type"`
+	ID            string             `json:"id"`
+	SessionID     string             `json:"session_id,omitempty"`
+	Title         string             `json:"title"`
+	Body          string             `json:"body"`
+	CreatedAt     time.Time          `json:"created_at"`
+	Notebook      string             `json:"notebook,omitempty"`
+	Tags          []string           `json:"tags,omitempty"`
+	ActionItems   []store.ActionItem `json:"action_items,omitempty"`
+	Decisions     []string           `json:"decisions,omitempty"`
+	OpenQuestions []string           `json:"open_questions,omitempty"`
+}
+
+// Session writes the session header and its messages.
+func (jsonlFormat) Session(s *store.Session) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(jsonlSession{
+		Type: "session", ID: s.ID, Title: s.DisplayTitle(), Description: s.Description,
+		CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt, Usage: s.Usage,
+	}); err != nil {
+		return nil, err
+	}
+	for i, msg := range s.Chat {
+		if err := enc.Encode(jsonlMessage{
+			Type: "message", SessionID: s.ID, Index: i, Role: msg.Role,
+			Content: msg.Content, Attachments: attachmentList(msg.Parts),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Note writes the note as a single line.
+func (jsonlFormat) Note(n *store.Note) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(jsonlNote{
+		Type: "note", ID: n.ID, SessionID: n.SessionID, Title: n.Title, Body: n.Body,
+		CreatedAt: n.CreatedAt, Notebook: n.Notebook, Tags: n.Tags,
+		ActionItems: n.ActionItems, Decisions: n.Decisions, OpenQuestions: n.OpenQuestions,
+	})
+	return buf.Bytes(), err
+}
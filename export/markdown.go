@@ -0,0 +1,48 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// markdownFormat writes transcripts with a heading per message and notes as
+// plain markdown.
+type markdownFormat struct{}
+
+func (markdownFormat) Ext() string { return ".md" }
+
+// Session renders the transcript with role headings.
+func (markdownFormat) Session(s *store.Session) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", s.DisplayTitle())
+	if s.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", s.Description)
+	}
+	fmt.Fprintf(&b, "_Created %s · %d messages_\n", s.CreatedAt.Format("2006-01-02 15:04"), len(s.Chat))
+	for _, msg := range s.Chat {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", roleName(msg.Role), strings.TrimSpace(msg.Content))
+		if files := attachmentList(msg.Parts); len(files) > 0 {
+			b.WriteString("\nAttachments:\n\n")
+			for _, f := range files {
+				fmt.Fprintf(&b, "- %s\n", f)
+			}
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// Note renders the note with its metadata as a list under the title.
+func (markdownFormat) Note(n *store.Note) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", n.Title)
+	if meta := noteMeta(n); len(meta) > 0 {
+		for _, m := range meta {
+			fmt.Fprintf(&b, "- **%s:** %s\n", m[0], m[1])
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.TrimSpace(n.Markdown()) + "\n")
+	return []byte(b.String()), nil
+}
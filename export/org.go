@@ -0,0 +1,110 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// orgFormat writes Emacs org-mode documents.
+type orgFormat struct{}
+
+func (orgFormat) Ext() string { return ".org" }
+
+// Session renders a top-level heading per message.
+func (orgFormat) Session(s *store.Session) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#+TITLE: %s\n", s.DisplayTitle())
+	fmt.Fprintf(&b, "#+DATE: [%s]\n", s.CreatedAt.Format("2006-01-02 Mon 15:04"))
+	if s.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", s.Description)
+	}
+	for _, msg := range s.Chat {
+		fmt.Fprintf(&b, "\n* %s\n\n%s\n", roleName(msg.Role), markdownToOrg(msg.Content, 1))
+		if files := attachmentList(msg.Parts); len(files) > 0 {
+			b.WriteString("\nAttachments:\n")
+			for _, f := range files {
+				fmt.Fprintf(&b, "- %s\n", f)
+			}
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// Note renders the note with its metadata as org keywords.
+func (orgFormat) Note(n *store.Note) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#+TITLE: %s\n", n.Title)
+	if !n.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, "#+DATE: [%s]\n", n.CreatedAt.Format("2006-01-02 Mon 15:04"))
+	}
+	if len(n.Tags) > 0 {
+		fmt.Fprintf(&b, "#+FILETAGS: :%s:\n", strings.Join(n.Tags, ":"))
+	}
+	if n.Notebook != "" {
+		fmt.Fprintf(&b, "#+CATEGORY: %s\n", n.Notebook)
+	}
+	fmt.Fprintf(&b, "\n%s\n", markdownToOrg(n.Markdown(), 0))
+	return []byte(b.String()), nil
+}
+
+var (
+	mdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet  = regexp.MustCompile(`^(\s*)[*+]\s+`)
+	mdTask    = regexp.MustCompile(`^(\s*)- \[([ xX])\] `)
+	mdLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBold    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdCode    = regexp.MustCompile("`([^`]+)`")
+)
+
+// markdownToOrg converts the common markdown constructs of chat replies and
+// notes to org-mode: fenced code becomes source blocks, headings become
+// headings below level, and bullets, tasks, links, bold and inline code use
+// org syntax.
+func markdownToOrg(md string, level int) string {
+	var b strings.Builder
+	inCode := false
+	for _, line := range strings.Split(strings.TrimSpace(md), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				b.WriteString("#+END_SRC\n")
+			} else {
+				b.WriteString(strings.TrimSpace("#+BEGIN_SRC "+strings.TrimPrefix(trimmed, "```")) + "\n")
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			// org treats lines starting with * or #+ specially even in blocks
+			if strings.HasPrefix(line, "*") || strings.HasPrefix(line, "#+") {
+				line = "," + line
+			}
+			b.WriteString(line + "\n")
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			b.WriteString(strings.Repeat("*", level+len(m[1])) + " " + inlineToOrg(m[2]) + "\n")
+			continue
+		}
+		line = mdTask.ReplaceAllStringFunc(line, func(s string) string {
+			m := mdTask.FindStringSubmatch(s)
+			return m[1] + "- [" + strings.ToUpper(m[2]) + "] "
+		})
+		line = mdBullet.ReplaceAllString(line, "$1- ")
+		b.WriteString(inlineToOrg(line) + "\n")
+	}
+	if inCode {
+		b.WriteString("#+END_SRC\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// inlineToOrg converts markdown links, bold text and inline code.
+func inlineToOrg(s string) string {
+	s = mdLink.ReplaceAllString(s, "[[$2][$1]]")
+	s = mdBold.ReplaceAllString(s, "*$1*")
+	return mdCode.ReplaceAllString(s, "~$1~")
+}
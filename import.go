@@ -0,0 +1,64 @@
+package main
+
+import (
+   "errors"
+   "flag"
+   "fmt"
+   "os"
+   "path/filepath"
+   "strings"
+
+   "github.com/sergey-suslov/ai-notes/importer"
+)
+
+// runImport implements "ai-notes import": it imports a ChatGPT export or a
+// JSONL chat log as sessions and prints a report.
+func runImport(args []string) error {
+   fs := flag.NewFlagSet("import", flag.ContinueOnError)
+   format := fs.String("format", "", "chatgpt or jsonl (default: by file extension)")
+   branches := fs.String("branches", importer.BranchesFlatten, "flatten: keep the last shown branch; preserve: a session per branch")
+   dryRun := fs.Bool("n", false, "only report what would be imported")
+   fs.Usage = func() {
+       fmt.Fprintf(fs.Output(), "usage: ai-notes import [flags] FILE\n\n")
+       fs.PrintDefaults()
+   }
+   if err := fs.Parse(args); err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           return nil
+       }
+       return err
+   }
+   if fs.NArg() != 1 {
+       fs.Usage()
+       return fmt.Errorf("expected one file to import")
+   }
+   if *branches != importer.BranchesFlatten && *branches != importer.BranchesPreserve {
+       return fmt.Errorf("unknown branch mode %q", *branches)
+   }
+   path := fs.Arg(0)
+   if *format == "" {
+       *format = "chatgpt"
+       if strings.EqualFold(filepath.Ext(path), ".jsonl") {
+           *format = "jsonl"
+       }
+   }
+   f, err := os.Open(path)
+   if err != nil {
+       return err
+   }
+   defer f.Close()
+   opts := importer.Options{Branches: *branches, DryRun: *dryRun}
+   var report *importer.Report
+   switch *format {
+   case "chatgpt":
+       report, err = importer.ChatGPT(f, opts)
+   case "jsonl":
+       report, err = importer.JSONL(f, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), opts)
+   default:
+       return fmt.Errorf("unknown import format %q", *format)
+   }
+   if report != nil {
+       fmt.Print(report)
+   }
+   return err
+}
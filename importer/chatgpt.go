@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// chatgptConversation is an entry of ChatGPT's conversations.json export.
+// Messages form a tree in Mapping; editing or regenerating a message starts
+// a new branch and CurrentNode is the leaf that was last shown.
+type chatgptConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	UpdateTime     float64                `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatgptNode `json:"mapping"`
+}
+
+type chatgptNode struct {
+	ID       string          `json:"id"`
+	Message  *chatgptMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+type chatgptMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+	Metadata struct {
+		IsVisuallyHidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// ChatGPT imports the conversations of a ChatGPT conversations.json export.
+func ChatGPT(r io.Reader, opts Options) (*Report, error) {
+	var convs []chatgptConversation
+	if err := json.NewDecoder(r).Decode(&convs); err != nil {
+		return nil, fmt.Errorf("parsing conversations.json: %w", err)
+	}
+	report := &Report{}
+	var sessions []*store.Session
+	for _, c := range convs {
+		sessions = append(sessions, c.sessions(opts.Branches, report)...)
+	}
+	if err := save(sessions, opts, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// sessions converts the conversation into one session, or one per branch
+// when branches are preserved.
+func (c *chatgptConversation) sessions(branches string, report *Report) []*store.Session {
+	id := c.ConversationID
+	if id == "" {
+		id = c.ID
+	}
+	leaves := []string{c.CurrentNode}
+	if branches == BranchesPreserve {
+		leaves = c.leaves()
+	}
+	var out []*store.Session
+	for i, leaf := range leaves {
+		s := &store.Session{
+			ID:        "chatgpt-" + id,
+			Title:     c.Title,
+			CreatedAt: unixTime(c.CreateTime),
+			UpdatedAt: unixTime(c.UpdateTime),
+		}
+		if i > 0 {
+			// name branches after their leaf so re-imports match them
+			s.ID = s.ID + "-" + leaf
+			s.Title = fmt.Sprintf("%s (branch %d)", c.Title, i+1)
+		}
+		for _, node := range c.path(leaf) {
+			msg, ok := node.Message.toMessage()
+			if !ok {
+				if node.Message != nil && node.Message.Author.Role == "tool" {
+					report.Dropped++
+				}
+				continue
+			}
+			s.Chat = append(s.Chat, msg)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// path returns the nodes from the root to leaf.
+func (c *chatgptConversation) path(leaf string) []chatgptNode {
+	var nodes []chatgptNode
+	seen := make(map[string]bool)
+	for id := leaf; id != "" && !seen[id]; {
+		seen[id] = true
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		nodes = append(nodes, node)
+		id = node.Parent
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return nodes
+}
+
+// leaves returns every branch end, the current one first and the others in
+// the order they were created.
+func (c *chatgptConversation) leaves() []string {
+	leaves := []string{c.CurrentNode}
+	var others []chatgptNode
+	for id, node := range c.Mapping {
+		if len(node.Children) == 0 && id != c.CurrentNode {
+			node.ID = id
+			others = append(others, node)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].Message.time().Before(others[j].Message.time())
+	})
+	for _, node := range others {
+		leaves = append(leaves, node.ID)
+	}
+	return leaves
+}
+
+// toMessage maps a ChatGPT message to a session message. Hidden, empty and
+// tool messages are left out.
+func (m *chatgptMessage) toMessage() (store.Message, bool) {
+	if m == nil || m.Metadata.IsVisuallyHidden {
+		return store.Message{}, false
+	}
+	role, ok := mapRole(m.Author.Role)
+	if !ok {
+		return store.Message{}, false
+	}
+	var texts []string
+	for _, raw := range m.Content.Parts {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			if s != "" {
+				texts = append(texts, s)
+			}
+			continue
+		}
+		// non-text parts are uploaded images and files
+		texts = append(texts, "[attachment not imported]")
+	}
+	if m.Content.Text != "" {
+		text := m.Content.Text
+		if m.Content.ContentType == "code" {
+			text = "```\n" + text + "\n```"
+		}
+		texts = append(texts, text)
+	}
+	content := strings.TrimSpace(strings.Join(texts, "\n\n"))
+	if content == "" {
+		return store.Message{}, false
+	}
+	return store.Message{Role: role, Content: content, Time: timePtr(m.time())}, true
+}
+
+// time returns when the message was written.
+func (m *chatgptMessage) time() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	return unixTime(m.CreateTime)
+}
+
+// unixTime converts fractional Unix seconds, returning the zero time for 0.
+func unixTime(sec float64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
@@ -0,0 +1,135 @@
+// Package importer turns chat histories exported by other tools into sessions.
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// Branch modes for conversations that were edited or regenerated.
+const (
+	// BranchesFlatten keeps only the branch that was last shown.
+	BranchesFlatten = "flatten"
+	// BranchesPreserve imports every branch as its own session.
+	BranchesPreserve = "preserve"
+)
+
+// Options controls an import.
+type Options struct {
+	// Branches is BranchesFlatten (the default) or BranchesPreserve.
+	Branches string
+	// DryRun reports what would be imported without saving anything.
+	DryRun bool
+}
+
+// Result is what happened to one imported conversation or note.
+type Result struct {
+	ID, Title string
+	Messages  int    // messages of an imported session
+	Status    string // "imported", "updated" or "skipped"
+	Reason    string // why a conversation was skipped
+}
+
+// Report lists the outcome of every conversation or note in an import.
+type Report struct {
+	Results []Result
+	// Dropped counts messages that could not be mapped, e.g. tool output.
+	Dropped int
+}
+
+// count returns how many results have status.
+func (r *Report) count(status string) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders the report as one line per conversation or note and a summary.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "%-8s %s %q", res.Status, res.ID, res.Title)
+		if res.Messages > 0 {
+			fmt.Fprintf(&b, " (%d messages)", res.Messages)
+		}
+		if res.Reason != "" {
+			b.WriteString(": " + res.Reason)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d imported, %d updated, %d skipped", r.count("imported"), r.count("updated"), r.count("skipped"))
+	if r.Dropped > 0 {
+		fmt.Fprintf(&b, ", %d messages dropped", r.Dropped)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// mapRole converts a foreign role name to a session role. ok is false for
+// messages that have no place in a session, such as tool output.
+func mapRole(role string) (mapped string, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "user", "human", "customer":
+		return "user", true
+	case "assistant", "ai", "bot", "model", "gpt":
+		return "assistant", true
+	case "system", "developer":
+		return "system", true
+	}
+	return "", false
+}
+
+// save stores sessions that are new or have grown since the last import and
+// records the outcome in report. Sessions are matched by ID, which importers
+// derive from the source conversation so re-imports find earlier copies.
+func save(sessions []*store.Session, opts Options, report *Report) error {
+	existing, err := store.LoadSessions()
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*store.Session, len(existing))
+	for _, s := range existing {
+		byID[s.ID] = s
+	}
+	for _, s := range sessions {
+		res := Result{ID: s.ID, Title: s.DisplayTitle(), Messages: len(s.Chat), Status: "imported"}
+		if len(s.Chat) == 0 {
+			res.Status, res.Reason = "skipped", "no messages"
+			report.Results = append(report.Results, res)
+			continue
+		}
+		if old, ok := byID[s.ID]; ok {
+			if len(old.Chat) >= len(s.Chat) {
+				res.Status, res.Reason = "skipped", "already imported"
+				report.Results = append(report.Results, res)
+				continue
+			}
+			// keep what was added locally to the earlier import
+			res.Status = "updated"
+			s.Title, s.Description = old.Title, old.Description
+			s.Usage, s.NotedUpTo, s.LivingNoteID, s.Notebook = old.Usage, old.NotedUpTo, old.LivingNoteID, old.Notebook
+		}
+		if !opts.DryRun {
+			if err := s.Save(); err != nil {
+				return fmt.Errorf("saving session %s: %w", s.ID, err)
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+	return nil
+}
+
+// timePtr returns &t, or nil for the zero time.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,157 @@
+package importer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// jsonlLine is one line of a generic chat log. Field names vary between
+// tools, so several common spellings are accepted.
+type jsonlLine map[string]any
+
+// str returns the first of keys holding a non-empty string.
+func (l jsonlLine) str(keys ...string) string {
+	for _, k := range keys {
+		if s, ok := l[k].(string); ok && s != "" {
+			return s
+		}
+		// e.g. {"author": {"role": "user"}}
+		if obj, ok := l[k].(map[string]any); ok {
+			if s, ok := obj["role"].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// time returns the first of keys holding an RFC 3339 timestamp or Unix
+// seconds or milliseconds.
+func (l jsonlLine) time(keys ...string) time.Time {
+	for _, k := range keys {
+		switch v := l[k].(type) {
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t
+			}
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return epoch(f)
+			}
+		case float64:
+			return epoch(v)
+		}
+	}
+	return time.Time{}
+}
+
+// epoch converts Unix seconds, or milliseconds for values too large to be
+// seconds, to a time.
+func epoch(v float64) time.Time {
+	if v > 1e12 {
+		v /= 1000
+	}
+	return unixTime(v)
+}
+
+var (
+	conversationKeys = []string{"session_id", "conversation_id", "thread_id", "chat_id"}
+	timeKeys         = []string{"time", "timestamp", "created_at", "create_time"}
+)
+
+// JSONL imports a chat log with one JSON message object per line, such as
+// {"conversation_id": "...", "role": "user", "content": "...", "timestamp": ...}.
+// Messages are grouped into sessions by conversation ID, in file order; a
+// log without conversation IDs is one session. Lines with "type": "session",
+// as written by the JSONL exporter, set the title of their session. name is
+// used as the title of sessions that have none. Logs are linear, so the
+// branch option does not apply.
+func JSONL(r io.Reader, name string, opts Options) (*Report, error) {
+	report := &Report{}
+	var order []string
+	convs := make(map[string]*store.Session)
+	get := func(id string) *store.Session {
+		s, ok := convs[id]
+		if !ok {
+			s = &store.Session{}
+			convs[id] = s
+			order = append(order, id)
+		}
+		return s
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var line jsonlLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if line.str("type") == "session" {
+			s := get(line.str("id"))
+			s.Title, s.Description = line.str("title"), line.str("description")
+			s.CreatedAt = line.time("created_at")
+			continue
+		}
+		role, ok := mapRole(line.str("role", "author", "speaker", "from"))
+		content := line.str("content", "text", "message")
+		if !ok || content == "" {
+			report.Dropped++
+			continue
+		}
+		s := get(line.str(conversationKeys...))
+		t := line.time(timeKeys...)
+		s.Chat = append(s.Chat, store.Message{Role: role, Content: content, Time: timePtr(t)})
+		if s.CreatedAt.IsZero() || (!t.IsZero() && t.Before(s.CreatedAt)) {
+			s.CreatedAt = t
+		}
+		if t.After(s.UpdatedAt) {
+			s.UpdatedAt = t
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	var sessions []*store.Session
+	for _, conv := range order {
+		s := convs[conv]
+		s.ID = "jsonl-" + jsonlID(conv, s)
+		if s.Title == "" {
+			s.Title = name
+			if conv != "" {
+				s.Title += " " + conv
+			}
+		}
+		if s.CreatedAt.IsZero() {
+			s.CreatedAt = time.Now()
+		}
+		sessions = append(sessions, s)
+	}
+	if err := save(sessions, opts, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// jsonlID derives a stable session ID from the conversation ID, or from the
+// first message for logs without one.
+func jsonlID(conv string, s *store.Session) string {
+	key := "conversation:" + conv
+	if conv == "" && len(s.Chat) > 0 {
+		key = "message:" + s.Chat[0].Content
+		if t := s.Chat[0].Time; t != nil {
+			key += t.Format(time.RFC3339Nano)
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"errors"
+	"os"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// Vault copies the markdown files of an Obsidian or Logseq vault, or any
+// folder of .md files, into ~/.ai-notes/notes. The vault itself is only
+// read. Note IDs are derived from the file paths, so files imported before
+// are skipped.
+func Vault(dir string, opts Options) (*Report, error) {
+	notes, err := store.LoadVault(dir)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	for _, n := range notes {
+		res := Result{ID: n.ID, Title: n.Title, Status: "imported"}
+		if _, err := store.LoadNote(n.ID); err == nil {
+			res.Status, res.Reason = "skipped", "already imported"
+			report.Results = append(report.Results, res)
+			continue
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return report, err
+		}
+		n.External = ""
+		if !opts.DryRun {
+			if _, err := n.Save(); err != nil {
+				return report, err
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report, nil
+}
@@ -3,12 +3,66 @@ package main
 import (
    "fmt"
    "os"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   "github.com/sergey-suslov/ai-notes/store"
    "github.com/sergey-suslov/ai-notes/ui"
 )
 
 func main() {
-   if err := ui.Run(); err != nil {
+   command := ""
+   if len(os.Args) > 1 {
+       command = os.Args[1]
+   }
+   // an encrypted store is unlocked once, before any command reads it
+   if err := unlock(); err != nil {
        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
        os.Exit(1)
    }
-}
\ No newline at end of file
+   var err error
+   switch command {
+   case "rekey":
+       err = runRekey(os.Args[2:])
+   case "graph":
+       notebook := ""
+       if len(os.Args) > 2 {
+           notebook = store.NormalizeNotebook(os.Args[2])
+       }
+       err = printGraph(notebook)
+   case "export":
+       err = runExport(os.Args[2:])
+   case "import":
+       err = runImport(os.Args[2:])
+   case "vault":
+       err = runVault(os.Args[2:])
+   case "audit":
+       err = runAudit(os.Args[2:])
+   case "budget":
+       err = printBudgets()
+   case "serve":
+       err = runServe(os.Args[2:])
+   case "mcp":
+       err = runMCP(os.Args[2:])
+   default:
+       err = ui.Run()
+   }
+   if err != nil {
+       fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+       os.Exit(1)
+   }
+}
+
+// printGraph writes the wiki-link graph of the notes in notebook, or of all
+// notes if notebook is "", to stdout as Graphviz DOT.
+func printGraph(notebook string) error {
+   cfg, err := config.Load()
+   if err != nil {
+       return fmt.Errorf("loading config: %w", err)
+   }
+   notes, err := store.LoadAllNotes(cfg.Vaults)
+   if err != nil {
+       return fmt.Errorf("loading notes: %w", err)
+   }
+   fmt.Print(store.LinkGraphDOT(store.FilterNotebook(notes, notebook)))
+   return nil
+}
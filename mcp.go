@@ -0,0 +1,41 @@
+package main
+
+import (
+   "errors"
+   "flag"
+   "fmt"
+   "os"
+   "runtime/debug"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   "github.com/sergey-suslov/ai-notes/mcp"
+)
+
+// runMCP implements "ai-notes mcp": it serves the notes to an MCP client
+// over stdin and stdout until the client disconnects.
+func runMCP(args []string) error {
+   fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+   fs.Usage = func() {
+       fmt.Fprintf(fs.Output(), "usage: ai-notes mcp\n\n")
+       fmt.Fprintf(fs.Output(), "Serves the notes over the Model Context Protocol on stdin and stdout.\nConfigure it in an MCP client as the command \"ai-notes mcp\"; an encrypted\nstore needs its passphrase in $%s.\n", passphraseEnv)
+   }
+   if err := fs.Parse(args); err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           return nil
+       }
+       return err
+   }
+   cfg, err := config.Load()
+   if err != nil {
+       return fmt.Errorf("loading config: %w", err)
+   }
+   return mcp.NewServer(cfg.Vaults, buildVersion()).Serve(os.Stdin, os.Stdout)
+}
+
+// buildVersion returns the module version the binary was built from.
+func buildVersion() string {
+   if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+       return info.Main.Version
+   }
+   return "(devel)"
+}
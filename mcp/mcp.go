@@ -0,0 +1,261 @@
+// Package mcp serves the notes over the Model Context Protocol, so other AI
+// tools can use them as long-term memory. Messages are JSON-RPC 2.0, one
+// per line on stdin and stdout.
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+const (
+	// protocolVersion is the MCP revision implemented here.
+	protocolVersion = "2024-11-05"
+
+	// noteURIPrefix starts the URI of every note resource.
+	noteURIPrefix = "ai-notes://notes/"
+)
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// request is a JSON-RPC request, or a notification if ID is absent.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response carrying either Result or Error.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// invalidParams returns an error for malformed method parameters.
+func invalidParams(format string, args ...any) *rpcError {
+	return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// Server answers MCP requests from the notes store.
+type Server struct {
+	// vaults are indexed markdown folders served along with the notes
+	vaults []string
+	// version is reported to clients as the server version
+	version string
+
+	mu  sync.Mutex // serializes writes to out
+	out io.Writer
+}
+
+// NewServer returns a Server for the notes and the given vaults.
+func NewServer(vaults []string, version string) *Server {
+	return &Server{vaults: vaults, version: version}
+}
+
+// Serve reads requests from r and writes responses to w until r is closed.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.out = w
+	sc := bufio.NewScanner(r)
+	// note bodies can be long
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			s.write(response{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		result, err := s.handle(req)
+		if req.ID == nil {
+			// notifications get no response
+			continue
+		}
+		resp := response{ID: req.ID, Result: result}
+		if err != nil {
+			var rerr *rpcError
+			if !errors.As(err, &rerr) {
+				rerr = &rpcError{Code: codeInternalError, Message: err.Error()}
+			}
+			resp.Result, resp.Error = nil, rerr
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// write sends one response line.
+func (s *Server) write(resp response) error {
+	resp.JSONRPC = "2.0"
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("encoding response: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.out.Write(append(data, '\n'))
+	return err
+}
+
+// handle dispatches a request to its method.
+func (s *Server) handle(req request) (any, error) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: `jsonrpc must be "2.0"`}
+	}
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return struct{}{}, nil
+	case "resources/list":
+		return s.listResources()
+	case "resources/read":
+		return s.readResource(req.Params)
+	case "tools/list":
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		return s.callTool(req.Params)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+// initialize answers the client's handshake.
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	return map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities": map[string]any{
+			"resources": map[string]any{},
+			"tools":     map[string]any{},
+		},
+		"serverInfo": map[string]string{"name": "ai-notes", "version": s.version},
+		"instructions": "These are the user's notes, summarized from AI chat sessions and kept as long-term memory. " +
+			"Search them before answering questions about past work, and save what is worth remembering.",
+	}, nil
+}
+
+// resource describes a note in resources/list.
+type resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType"`
+}
+
+// noteURI returns the resource URI of a note.
+func noteURI(n *store.Note) string {
+	return noteURIPrefix + url.PathEscape(n.ID)
+}
+
+// listResources lists every note as a resource.
+func (s *Server) listResources() (any, error) {
+	notes, err := store.LoadAllNotes(s.vaults)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]resource, len(notes))
+	for i, n := range notes {
+		out[i] = resource{URI: noteURI(n), Name: n.Title, Description: describe(n), MimeType: "text/markdown"}
+	}
+	return map[string]any{"resources": out}, nil
+}
+
+// readResource returns the markdown of the note named by the "uri" param.
+func (s *Server) readResource(params json.RawMessage) (any, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, invalidParams("invalid params: %v", err)
+	}
+	rest, ok := strings.CutPrefix(p.URI, noteURIPrefix)
+	if !ok {
+		return nil, invalidParams("unknown resource %q", p.URI)
+	}
+	id, err := url.PathUnescape(rest)
+	if err != nil {
+		return nil, invalidParams("unknown resource %q", p.URI)
+	}
+	n, err := s.findNote(id)
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+	return map[string]any{"contents": []map[string]string{{
+		"uri":      p.URI,
+		"mimeType": "text/markdown",
+		"text":     noteText(n),
+	}}}, nil
+}
+
+// findNote returns the note or vault note with the given ID.
+func (s *Server) findNote(id string) (*store.Note, error) {
+	notes, err := store.LoadAllNotes(s.vaults)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notes {
+		if n.ID == id {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("note %q not found", id)
+}
+
+// describe summarizes a note's metadata on one line.
+func describe(n *store.Note) string {
+	var parts []string
+	if !n.CreatedAt.IsZero() {
+		parts = append(parts, n.CreatedAt.Format("2006-01-02"))
+	}
+	if n.Notebook != "" {
+		parts = append(parts, "notebook "+n.Notebook)
+	}
+	if len(n.Tags) > 0 {
+		parts = append(parts, "#"+strings.Join(n.Tags, " #"))
+	}
+	if n.External != "" {
+		parts = append(parts, "read-only")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// noteText renders a note for clients: its title, metadata and content.
+func noteText(n *store.Note) string {
+	text := "# " + n.Title + "\n\n"
+	if d := describe(n); d != "" {
+		text += "_" + d + "_\n\n"
+	}
+	return text + n.Markdown()
+}
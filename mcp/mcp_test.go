@@ -0,0 +1,152 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// reply is a response line as a client decodes it.
+type reply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	} `json:"result"`
+	Error *rpcError `json:"error"`
+}
+
+// serve feeds lines to a Server for vaults and returns its response lines.
+func serve(t *testing.T, vaults []string, lines ...string) []reply {
+	t.Helper()
+	var out strings.Builder
+	in := strings.NewReader(strings.Join(lines, "\n") + "\n")
+	if err := NewServer(vaults, "test").Serve(in, &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	var replies []reply
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r reply
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("response %q: %v", line, err)
+		}
+		if r.JSONRPC != "2.0" {
+			t.Errorf("response %q: jsonrpc = %q, want 2.0", line, r.JSONRPC)
+		}
+		replies = append(replies, r)
+	}
+	return replies
+}
+
+// call returns a tools/call request line with id 1.
+func call(name string, args map[string]any) string {
+	data, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	return string(data)
+}
+
+func TestServe(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	vault := t.TempDir()
+	if err := os.WriteFile(filepath.Join(vault, "Deploy.md"), []byte("# Deploy\n\nRun make.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vaultID := store.VaultNoteID(vault, "Deploy.md")
+
+	tests := []struct {
+		name string
+		line string
+		// wantID is the expected response ID, or "" for no response
+		wantID   string
+		wantCode int
+		// wantError expects a tool result with isError set
+		wantError bool
+		// wantText is a substring of the tool result text
+		wantText string
+	}{
+		{name: "parse error", line: `{"jsonrpc":`, wantID: "null", wantCode: codeParseError},
+		{name: "notification", line: `{"jsonrpc":"2.0","method":"notifications/initialized"}`},
+		{name: "unknown notification", line: `{"jsonrpc":"2.0","method":"no/such"}`},
+		{name: "ping", line: `{"jsonrpc":"2.0","id":7,"method":"ping"}`, wantID: "7"},
+		{name: "invalid jsonrpc", line: `{"jsonrpc":"1.0","id":1,"method":"ping"}`, wantID: "1", wantCode: codeInvalidRequest},
+		{name: "unknown method", line: `{"jsonrpc":"2.0","id":"a","method":"no/such"}`, wantID: `"a"`, wantCode: codeMethodNotFound},
+		{name: "unknown tool", line: call("no_such", nil), wantID: "1", wantCode: codeInvalidParams},
+		{name: "missing note", line: call("get_note", map[string]any{"id": "nope"}), wantID: "1", wantError: true, wantText: "not found"},
+		{name: "empty title", line: call("create_note", map[string]any{"title": " ", "body": "b"}), wantID: "1", wantError: true, wantText: "title is empty"},
+		{name: "create note", line: call("create_note", map[string]any{"title": "Release", "body": "Tag it."}), wantID: "1", wantText: "Created note"},
+		{name: "vault note", line: call("get_note", map[string]any{"id": vaultID}), wantID: "1", wantText: "Run make."},
+		{name: "append to vault note", line: call("append_to_note", map[string]any{"id": vaultID, "text": "more"}), wantID: "1", wantError: true, wantText: store.ErrReadOnly.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replies := serve(t, []string{vault}, tt.line)
+			if tt.wantID == "" {
+				if len(replies) != 0 {
+					t.Fatalf("got %d responses to a notification, want none", len(replies))
+				}
+				return
+			}
+			if len(replies) != 1 {
+				t.Fatalf("got %d responses, want 1", len(replies))
+			}
+			r := replies[0]
+			if string(r.ID) != tt.wantID {
+				t.Errorf("id = %s, want %s", r.ID, tt.wantID)
+			}
+			if tt.wantCode != 0 {
+				if r.Error == nil || r.Error.Code != tt.wantCode {
+					t.Fatalf("error = %+v, want code %d", r.Error, tt.wantCode)
+				}
+				return
+			}
+			if r.Error != nil {
+				t.Fatalf("unexpected error %+v", r.Error)
+			}
+			if r.Result.IsError != tt.wantError {
+				t.Errorf("isError = %v, want %v", r.Result.IsError, tt.wantError)
+			}
+			if tt.wantText != "" {
+				if len(r.Result.Content) != 1 || !strings.Contains(r.Result.Content[0].Text, tt.wantText) {
+					t.Errorf("content = %+v, want it to contain %q", r.Result.Content, tt.wantText)
+				}
+			}
+		})
+	}
+}
+
+func TestServeAppendToNote(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	n := store.NewNote("", "Tag it.")
+	n.Title = "Release"
+	if _, err := n.Save(); err != nil {
+		t.Fatal(err)
+	}
+	replies := serve(t, nil,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		call("append_to_note", map[string]any{"id": n.ID, "text": "Push the tag."}),
+	)
+	if len(replies) != 1 || replies[0].Result.IsError {
+		t.Fatalf("responses = %+v, want one successful result", replies)
+	}
+	saved, err := store.LoadNote(n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(saved.Body, "Tag it.\n\nPush the tag.") {
+		t.Errorf("body = %q, want the text appended", saved.Body)
+	}
+}
@@ -0,0 +1,220 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// tool describes a tool in tools/list.
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// schema builds an object schema from property schemas and required names.
+func schema(props map[string]any, required ...string) map[string]any {
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+var (
+	stringProp = func(desc string) map[string]any { return map[string]any{"type": "string", "description": desc} }
+	tagsProp   = map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Tags, e.g. [\"golang\", \"deploy\"]."}
+)
+
+// tools are the tools offered to clients.
+var tools = []tool{
+	{
+		Name:        "search_notes",
+		Description: "Search the notes. Returns the ID, title and metadata of notes containing every word of the query, newest first.",
+		InputSchema: schema(map[string]any{
+			"query":    stringProp("Words to search for in titles, content and tags. Empty lists all notes."),
+			"tag":      stringProp("Only notes with this tag."),
+			"notebook": stringProp("Only notes in this notebook, e.g. \"work/projects\", or its sub-notebooks."),
+			"limit":    map[string]any{"type": "integer", "description": "Maximum number of results, 20 by default."},
+		}),
+	},
+	{
+		Name:        "get_note",
+		Description: "Read a note by ID, as markdown.",
+		InputSchema: schema(map[string]any{"id": stringProp("The note ID.")}, "id"),
+	},
+	{
+		Name:        "create_note",
+		Description: "Save a new note. Link other notes by title with [[Title]].",
+		InputSchema: schema(map[string]any{
+			"title":    stringProp("The note title."),
+			"body":     stringProp("The note content, as markdown."),
+			"tags":     tagsProp,
+			"notebook": stringProp("Notebook to file the note in, e.g. \"work/projects\"."),
+		}, "title", "body"),
+	},
+	{
+		Name:        "append_to_note",
+		Description: "Append markdown to the end of an existing note. The previous version is kept in the note's history.",
+		InputSchema: schema(map[string]any{
+			"id":   stringProp("The note ID."),
+			"text": stringProp("Markdown to append."),
+		}, "id", "text"),
+	},
+}
+
+// defaultSearchLimit is how many notes search_notes returns by default.
+const defaultSearchLimit = 20
+
+// callTool runs a tool. Failures of the tool itself are reported in the
+// result with isError set, as MCP asks, so the calling model can see them.
+func (s *Server) callTool(params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, invalidParams("invalid params: %v", err)
+	}
+	if len(p.Arguments) == 0 {
+		p.Arguments = json.RawMessage("{}")
+	}
+	var (
+		text string
+		err  error
+	)
+	switch p.Name {
+	case "search_notes":
+		text, err = s.searchNotes(p.Arguments)
+	case "get_note":
+		text, err = s.getNote(p.Arguments)
+	case "create_note":
+		text, err = s.createNote(p.Arguments)
+	case "append_to_note":
+		text, err = s.appendToNote(p.Arguments)
+	default:
+		return nil, invalidParams("unknown tool %q", p.Name)
+	}
+	result := map[string]any{}
+	if err != nil {
+		text = "Error: " + err.Error()
+		result["isError"] = true
+	}
+	result["content"] = []map[string]string{{"type": "text", "text": text}}
+	return result, nil
+}
+
+// searchNotes implements the search_notes tool.
+func (s *Server) searchNotes(args json.RawMessage) (string, error) {
+	var a struct {
+		Query    string `json:"query"`
+		Tag      string `json:"tag"`
+		Notebook string `json:"notebook"`
+		Limit    int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if a.Limit <= 0 {
+		a.Limit = defaultSearchLimit
+	}
+	notes, err := store.LoadAllNotes(s.vaults)
+	if err != nil {
+		return "", err
+	}
+	if a.Notebook != "" {
+		notes = store.FilterNotebook(notes, store.NormalizeNotebook(a.Notebook))
+	}
+	tag := strings.TrimPrefix(strings.ToLower(a.Tag), "#")
+	var b strings.Builder
+	found := 0
+	for _, n := range notes {
+		if tag != "" && !n.HasTag(tag) || !n.Matches(a.Query) {
+			continue
+		}
+		found++
+		if found <= a.Limit {
+			fmt.Fprintf(&b, "- %s: %s", n.ID, n.Title)
+			if d := describe(n); d != "" {
+				fmt.Fprintf(&b, " (%s)", d)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if found == 0 {
+		return "No matching notes.", nil
+	}
+	if found > a.Limit {
+		fmt.Fprintf(&b, "… and %d more; narrow the search to see them.\n", found-a.Limit)
+	}
+	return b.String(), nil
+}
+
+// getNote implements the get_note tool.
+func (s *Server) getNote(args json.RawMessage) (string, error) {
+	var a struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	n, err := s.findNote(a.ID)
+	if err != nil {
+		return "", err
+	}
+	return noteText(n), nil
+}
+
+// createNote implements the create_note tool.
+func (s *Server) createNote(args json.RawMessage) (string, error) {
+	var a struct {
+		Title    string   `json:"title"`
+		Body     string   `json:"body"`
+		Tags     []string `json:"tags"`
+		Notebook string   `json:"notebook"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if strings.TrimSpace(a.Title) == "" {
+		return "", fmt.Errorf("title is empty")
+	}
+	notes, err := store.LoadNotes()
+	if err != nil {
+		return "", err
+	}
+	n := store.NewNote("", a.Body)
+	n.Title = a.Title
+	n.Notebook = store.NormalizeNotebook(a.Notebook)
+	n.Tags = store.NormalizeTags(a.Tags, store.TagVocabulary(notes))
+	if _, err := n.Save(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Created note %s: %s", n.ID, n.Title), nil
+}
+
+// appendToNote implements the append_to_note tool.
+func (s *Server) appendToNote(args json.RawMessage) (string, error) {
+	var a struct {
+		ID   string `json:"id"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if strings.TrimSpace(a.Text) == "" {
+		return "", fmt.Errorf("text is empty")
+	}
+	n, err := s.findNote(a.ID)
+	if err != nil {
+		return "", err
+	}
+	n.Body = strings.TrimRight(n.Body, "\n") + "\n\n" + strings.TrimSpace(a.Text)
+	if _, err := n.Save(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Appended to note %s: %s", n.ID, n.Title), nil
+}
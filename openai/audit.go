@@ -0,0 +1,163 @@
+package openai
+
+import (
+   "bufio"
+   "context"
+   "crypto/sha256"
+   "encoding/hex"
+   "encoding/json"
+   "fmt"
+   "os"
+   "path/filepath"
+   "sync"
+   "time"
+
+   openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+   auditFileName = "audit.jsonl"
+
+   // endpointChat names the chat completions endpoint in audit entries.
+   endpointChat = "chat/completions"
+)
+
+// AuditEntry is one request recorded in the audit log.
+type AuditEntry struct {
+   Time     time.Time `json:"time"`
+   Session  string    `json:"session,omitempty"`
+   Endpoint string    `json:"endpoint"`
+   Model    string    `json:"model"`
+   // PayloadHash is the hex sha256 of the request JSON; Payload is the
+   // request itself, kept only when full payloads are audited.
+   PayloadHash string          `json:"payload_sha256"`
+   Payload     json.RawMessage `json:"payload,omitempty"`
+   Usage       *openai.Usage   `json:"usage,omitempty"`
+   Status      string          `json:"status"` // "ok" or "error"
+   Error       string          `json:"error,omitempty"`
+}
+
+// auditLog appends entries to a JSONL file.
+type auditLog struct {
+   mu   sync.Mutex
+   path string
+   full bool
+}
+
+// AuditPath returns the default audit log location (~/.ai-notes/audit.jsonl).
+func AuditPath() (string, error) {
+   home, err := os.UserHomeDir()
+   if err != nil {
+       return "", fmt.Errorf("could not determine home directory: %w", err)
+   }
+   return filepath.Join(home, ".ai-notes", auditFileName), nil
+}
+
+// EnableAudit makes the client record every request in the JSONL file at
+// path. If full is set the whole request is logged, otherwise only its hash.
+func (c *Client) EnableAudit(path string, full bool) {
+   c.audit = &auditLog{path: path, full: full}
+}
+
+// sessionKey is the context key for the session ID of a request.
+type sessionKey struct{}
+
+// WithSession returns a context that attributes requests made with it to
+// the session id in the audit log.
+func WithSession(ctx context.Context, id string) context.Context {
+   return context.WithValue(ctx, sessionKey{}, id)
+}
+
+// record appends an entry for req and its outcome. A request whose entry
+// could not be written is reported as failed.
+func (a *auditLog) record(ctx context.Context, req openai.ChatCompletionRequest, usage *openai.Usage, reqErr error) error {
+   payload, err := json.Marshal(req)
+   if err != nil {
+       return fmt.Errorf("encoding audit payload: %w", err)
+   }
+   sum := sha256.Sum256(payload)
+   e := AuditEntry{
+       Time:        time.Now(),
+       Endpoint:    endpointChat,
+       Model:       req.Model,
+       PayloadHash: hex.EncodeToString(sum[:]),
+       Usage:       usage,
+       Status:      "ok",
+   }
+   e.Session, _ = ctx.Value(sessionKey{}).(string)
+   if a.full {
+       e.Payload = payload
+   }
+   if reqErr != nil {
+       e.Status = "error"
+       e.Error = reqErr.Error()
+   }
+   line, err := json.Marshal(e)
+   if err != nil {
+       return fmt.Errorf("encoding audit entry: %w", err)
+   }
+   a.mu.Lock()
+   defer a.mu.Unlock()
+   if err := os.MkdirAll(filepath.Dir(a.path), 0o700); err != nil {
+       return fmt.Errorf("creating audit log dir: %w", err)
+   }
+   f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+   if err != nil {
+       return fmt.Errorf("opening audit log: %w", err)
+   }
+   defer f.Close()
+   if _, err := f.Write(append(line, '\n')); err != nil {
+       return fmt.Errorf("writing audit log: %w", err)
+   }
+   return nil
+}
+
+// AuditFilter selects audit entries; zero fields match everything.
+type AuditFilter struct {
+   From, To time.Time // From inclusive, To exclusive
+   Session  string
+}
+
+// match reports whether e is selected by f.
+func (f AuditFilter) match(e AuditEntry) bool {
+   if !f.From.IsZero() && e.Time.Before(f.From) {
+       return false
+   }
+   if !f.To.IsZero() && !e.Time.Before(f.To) {
+       return false
+   }
+   return f.Session == "" || e.Session == f.Session
+}
+
+// ReadAudit returns the entries of the audit log at path selected by f, in
+// the order they were written. A missing log has no entries.
+func ReadAudit(path string, f AuditFilter) ([]AuditEntry, error) {
+   file, err := os.Open(path)
+   if err != nil {
+       if os.IsNotExist(err) {
+           return nil, nil
+       }
+       return nil, fmt.Errorf("opening audit log: %w", err)
+   }
+   defer file.Close()
+   var entries []AuditEntry
+   sc := bufio.NewScanner(file)
+   // full payloads can be large
+   sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+   for line := 1; sc.Scan(); line++ {
+       if len(sc.Bytes()) == 0 {
+           continue
+       }
+       var e AuditEntry
+       if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+           return nil, fmt.Errorf("parsing audit log line %d: %w", line, err)
+       }
+       if f.match(e) {
+           entries = append(entries, e)
+       }
+   }
+   if err := sc.Err(); err != nil {
+       return nil, fmt.Errorf("reading audit log: %w", err)
+   }
+   return entries, nil
+}
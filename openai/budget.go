@@ -0,0 +1,328 @@
+package openai
+
+import (
+   "bufio"
+   "context"
+   "encoding/json"
+   "fmt"
+   "os"
+   "path/filepath"
+   "sync"
+   "time"
+
+   openai "github.com/sashabaranov/go-openai"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   "github.com/sergey-suslov/ai-notes/util"
+)
+
+const (
+   // Provider names this client in budgets.
+   Provider = "openai"
+
+   spendFileName = "spend.jsonl"
+
+   // estimatedCompletionTokens is reserved for the reply of a request that
+   // does not limit it.
+   estimatedCompletionTokens = 1000
+   // estimatedImageTokens is reserved for every image of a request.
+   estimatedImageTokens = 1000
+)
+
+// BudgetError is returned instead of sending a request once a budget is
+// exhausted. The request can be retried with WithBudgetOverride.
+type BudgetError struct {
+   Budget config.Budget
+   Spent  float64
+}
+
+func (e *BudgetError) Error() string {
+   return fmt.Sprintf("%s exhausted: %s spent", e.Budget, e.Budget.Format(e.Spent))
+}
+
+// spendEntry is one request recorded in the spend ledger.
+type spendEntry struct {
+   Time     time.Time `json:"time"`
+   Provider string    `json:"provider"`
+   Model    string    `json:"model"`
+   Tokens   int       `json:"tokens"`
+   Cost     float64   `json:"cost"` // USD
+}
+
+// budgets enforces the configured budgets using a ledger of past spending.
+type budgets struct {
+   mu       sync.Mutex
+   path     string
+   budgets  []config.Budget
+   entries  []spendEntry    // this month's spending
+   reserved []*spendEntry   // estimated spending of requests in flight
+   warned   map[string]bool // budget and period start, warned about
+   pending  []string        // warnings not yet taken
+}
+
+// SpendPath returns the default spend ledger location (~/.ai-notes/spend.jsonl).
+func SpendPath() (string, error) {
+   home, err := os.UserHomeDir()
+   if err != nil {
+       return "", fmt.Errorf("could not determine home directory: %w", err)
+   }
+   return filepath.Join(home, ".ai-notes", spendFileName), nil
+}
+
+// EnableBudgets makes the client enforce bs, tracking spending in the JSONL
+// ledger at path.
+func (c *Client) EnableBudgets(bs []config.Budget, path string) error {
+   for _, b := range bs {
+       if err := b.Validate(); err != nil {
+           return err
+       }
+   }
+   entries, err := readSpend(path, periodStart(config.PeriodMonthly, time.Now()))
+   if err != nil {
+       return err
+   }
+   c.budgets = &budgets{path: path, budgets: bs, entries: entries, warned: make(map[string]bool)}
+   return nil
+}
+
+// budgetOverrideKey is the context key set by WithBudgetOverride.
+type budgetOverrideKey struct{}
+
+// WithBudgetOverride returns a context whose requests are sent even if a
+// budget is exhausted, after the user agreed to it.
+func WithBudgetOverride(ctx context.Context) context.Context {
+   return context.WithValue(ctx, budgetOverrideKey{}, true)
+}
+
+// TakeBudgetWarnings returns the budget warnings raised since the last call.
+// Each budget warns once per period.
+func (c *Client) TakeBudgetWarnings() []string {
+   if c.budgets == nil {
+       return nil
+   }
+   c.budgets.mu.Lock()
+   defer c.budgets.mu.Unlock()
+   w := c.budgets.pending
+   c.budgets.pending = nil
+   return w
+}
+
+// BudgetStatus describes the spending of a budget in its current period.
+type BudgetStatus struct {
+   Budget config.Budget
+   Spent  float64
+}
+
+// ReadBudgets reports the current spending of each of bs, as recorded in
+// the spend ledger at path.
+func ReadBudgets(bs []config.Budget, path string) ([]BudgetStatus, error) {
+   now := time.Now()
+   entries, err := readSpend(path, periodStart(config.PeriodMonthly, now))
+   if err != nil {
+       return nil, err
+   }
+   ledger := &budgets{entries: entries}
+   out := make([]BudgetStatus, len(bs))
+   for i, b := range bs {
+       out[i] = BudgetStatus{Budget: b, Spent: ledger.spent(b, now)}
+   }
+   return out, nil
+}
+
+// reserve returns a BudgetError if a budget that applies to req is
+// exhausted, counting the estimated spending of requests in flight, unless
+// ctx overrides budgets. Otherwise it reserves the estimated spending of
+// req, so that concurrent requests see it, until add or release is called
+// with the returned reservation.
+func (bs *budgets) reserve(ctx context.Context, req openai.ChatCompletionRequest) (*spendEntry, error) {
+   completion := req.MaxTokens
+   if completion <= 0 {
+       completion = estimatedCompletionTokens
+   }
+   u := openai.Usage{PromptTokens: estimate(req.Messages), CompletionTokens: completion}
+   r := &spendEntry{
+       Time:     time.Now(),
+       Provider: Provider,
+       Model:    req.Model,
+       Tokens:   u.PromptTokens + u.CompletionTokens,
+       Cost:     Cost(req.Model, u),
+   }
+   override, _ := ctx.Value(budgetOverrideKey{}).(bool)
+   bs.mu.Lock()
+   defer bs.mu.Unlock()
+   for _, b := range bs.budgets {
+       if !applies(b, req.Model) {
+           continue
+       }
+       if spent := bs.spent(b, r.Time) + bs.inFlight(b, r.Time); spent >= b.Limit && !override {
+           return nil, &BudgetError{Budget: b, Spent: spent}
+       }
+       // without a price the requests would count as free
+       key := fmt.Sprintf("%s@price of %s", b, req.Model)
+       if b.Unit != config.UnitTokens && !Priced(req.Model) && !bs.warned[key] {
+           bs.warned[key] = true
+           bs.pending = append(bs.pending, fmt.Sprintf("no price is known for %s, so its requests do not count against the %s", req.Model, b))
+       }
+   }
+   bs.reserved = append(bs.reserved, r)
+   return r, nil
+}
+
+// release drops the reservation r of a request that failed.
+func (bs *budgets) release(r *spendEntry) {
+   bs.mu.Lock()
+   defer bs.mu.Unlock()
+   bs.unreserve(r)
+}
+
+// unreserve drops the reservation r. bs.mu must be held.
+func (bs *budgets) unreserve(r *spendEntry) {
+   for i, e := range bs.reserved {
+       if e == r {
+           bs.reserved = append(bs.reserved[:i], bs.reserved[i+1:]...)
+           return
+       }
+   }
+}
+
+// add records a completed request in the ledger in place of its
+// reservation r and queues warnings for budgets it pushed past their
+// threshold.
+func (bs *budgets) add(r *spendEntry, model string, u openai.Usage) error {
+   e := spendEntry{
+       Time:     time.Now(),
+       Provider: Provider,
+       Model:    model,
+       Tokens:   u.PromptTokens + u.CompletionTokens,
+       Cost:     Cost(model, u),
+   }
+   line, err := json.Marshal(e)
+   if err != nil {
+       return fmt.Errorf("encoding spend entry: %w", err)
+   }
+   bs.mu.Lock()
+   defer bs.mu.Unlock()
+   bs.unreserve(r)
+   bs.entries = append(bs.entries, e)
+   for _, b := range bs.budgets {
+       if !applies(b, model) {
+           continue
+       }
+       warnAt := b.WarnAt
+       if warnAt == 0 {
+           warnAt = config.DefaultBudgetWarnAt
+       }
+       spent := bs.spent(b, e.Time)
+       key := fmt.Sprintf("%s@%s", b, periodStart(b.Period, e.Time).Format(time.DateOnly))
+       if spent >= warnAt*b.Limit && !bs.warned[key] {
+           bs.warned[key] = true
+           bs.pending = append(bs.pending, fmt.Sprintf("%.0f%% of the %s used (%s)", 100*spent/b.Limit, b, b.Format(spent)))
+       }
+   }
+   if err := os.MkdirAll(filepath.Dir(bs.path), 0o700); err != nil {
+       return fmt.Errorf("creating spend ledger dir: %w", err)
+   }
+   f, err := os.OpenFile(bs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+   if err != nil {
+       return fmt.Errorf("opening spend ledger: %w", err)
+   }
+   defer f.Close()
+   if _, err := f.Write(append(line, '\n')); err != nil {
+       return fmt.Errorf("writing spend ledger: %w", err)
+   }
+   return nil
+}
+
+// spent sums what requests covered by b spent in its period containing now.
+func (bs *budgets) spent(b config.Budget, now time.Time) float64 {
+   start := periodStart(b.Period, now)
+   total := 0.0
+   for _, e := range bs.entries {
+       total += charge(b, e, start)
+   }
+   return total
+}
+
+// inFlight sums the reservations covered by b in its period containing now.
+func (bs *budgets) inFlight(b config.Budget, now time.Time) float64 {
+   start := periodStart(b.Period, now)
+   total := 0.0
+   for _, e := range bs.reserved {
+       total += charge(b, *e, start)
+   }
+   return total
+}
+
+// charge returns what e counts against b in the period beginning at start.
+func charge(b config.Budget, e spendEntry, start time.Time) float64 {
+   if e.Time.Before(start) || !applies(b, e.Model) || (b.Provider != "" && b.Provider != e.Provider) {
+       return 0
+   }
+   if b.Unit == config.UnitTokens {
+       return float64(e.Tokens)
+   }
+   return e.Cost
+}
+
+// estimate returns a rough count of the prompt tokens of msgs.
+func estimate(msgs []openai.ChatCompletionMessage) int {
+   n := 0
+   for _, m := range msgs {
+       n += util.EstimateTokens(m.Content)
+       for _, p := range m.MultiContent {
+           if p.Type == openai.ChatMessagePartTypeImageURL {
+               n += estimatedImageTokens
+           } else {
+               n += util.EstimateTokens(p.Text)
+           }
+       }
+       for _, call := range m.ToolCalls {
+           n += util.EstimateTokens(call.Function.Name + call.Function.Arguments)
+       }
+   }
+   return n
+}
+
+// applies reports whether b covers requests of this client to model.
+func applies(b config.Budget, model string) bool {
+   return (b.Provider == "" || b.Provider == Provider) && (b.Model == "" || b.Model == model)
+}
+
+// periodStart returns the start of the day or month containing t.
+func periodStart(period string, t time.Time) time.Time {
+   y, m, d := t.Date()
+   if period == config.PeriodMonthly {
+       d = 1
+   }
+   return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// readSpend returns the ledger entries at path made at or after since.
+func readSpend(path string, since time.Time) ([]spendEntry, error) {
+   f, err := os.Open(path)
+   if err != nil {
+       if os.IsNotExist(err) {
+           return nil, nil
+       }
+       return nil, fmt.Errorf("opening spend ledger: %w", err)
+   }
+   defer f.Close()
+   var entries []spendEntry
+   sc := bufio.NewScanner(f)
+   for line := 1; sc.Scan(); line++ {
+       if len(sc.Bytes()) == 0 {
+           continue
+       }
+       var e spendEntry
+       if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+           return nil, fmt.Errorf("parsing spend ledger line %d: %w", line, err)
+       }
+       if !e.Time.Before(since) {
+           entries = append(entries, e)
+       }
+   }
+   if err := sc.Err(); err != nil {
+       return nil, fmt.Errorf("reading spend ledger: %w", err)
+   }
+   return entries, nil
+}
@@ -2,15 +2,25 @@ package openai
 
 import (
    "context"
+   "errors"
    "fmt"
+   "io"
    "os"
+   "strings"
 
    openai "github.com/sashabaranov/go-openai"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   "github.com/sergey-suslov/ai-notes/store"
 )
 
 // Client wraps the OpenAI API client.
 type Client struct {
    c *openai.Client
+   // audit records every request, if enabled
+   audit *auditLog
+   // budgets limits spending, if any are configured
+   budgets *budgets
 }
 
 // NewClient creates a new OpenAI API client using the OPENAI_API_KEY environment variable.
@@ -23,14 +33,69 @@ func NewClient() (*Client, error) {
    return &Client{c: cli}, nil
 }
 
+// NewClientFromConfig creates a client like NewClient, with the audit log
+// and the budgets configured in cfg.
+func NewClientFromConfig(cfg *config.Config) (*Client, error) {
+   client, err := NewClient()
+   if err != nil {
+       return nil, fmt.Errorf("creating OpenAI client: %w", err)
+   }
+   if !cfg.Audit.Disabled {
+       path, err := AuditPath()
+       if err != nil {
+           return nil, err
+       }
+       // the log is plaintext, so full payloads would leak what the
+       // encrypted store protects
+       client.EnableAudit(path, cfg.Audit.FullPayload && !store.Encrypted())
+   }
+   if len(cfg.Budgets) > 0 {
+       path, err := SpendPath()
+       if err != nil {
+           return nil, err
+       }
+       if err := client.EnableBudgets(cfg.Budgets, path); err != nil {
+           return nil, fmt.Errorf("loading budgets: %w", err)
+       }
+   }
+   return client, nil
+}
+
 // ChatCompletion sends a list of messages to the OpenAI Chat Completion API and returns the response content.
 // model is the model name to use, e.g. "gpt-3.5-turbo".
 func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage, model string) (string, error) {
+   content, _, err := c.ChatCompletionUsage(ctx, messages, model)
+   return content, err
+}
+
+// ChatCompletionUsage is like ChatCompletion but also returns the token usage reported by the API.
+func (c *Client) ChatCompletionUsage(ctx context.Context, messages []openai.ChatCompletionMessage, model string) (string, openai.Usage, error) {
    req := openai.ChatCompletionRequest{
        Model:    model,
        Messages: messages,
    }
-   resp, err := c.c.CreateChatCompletion(ctx, req)
+   resp, err := c.create(ctx, req)
+   if err != nil {
+       return "", openai.Usage{}, err
+   }
+   if len(resp.Choices) == 0 {
+       return "", openai.Usage{}, fmt.Errorf("no choices returned from OpenAI")
+   }
+   return resp.Choices[0].Message.Content, resp.Usage, nil
+}
+
+// ChatCompletionJSON is like ChatCompletion but constrains the reply to JSON
+// matching schema. The returned string is the raw JSON reply.
+func (c *Client) ChatCompletionJSON(ctx context.Context, messages []openai.ChatCompletionMessage, model string, schema *openai.ChatCompletionResponseFormatJSONSchema) (string, error) {
+   req := openai.ChatCompletionRequest{
+       Model:    model,
+       Messages: messages,
+       ResponseFormat: &openai.ChatCompletionResponseFormat{
+           Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
+           JSONSchema: schema,
+       },
+   }
+   resp, err := c.create(ctx, req)
    if err != nil {
        return "", err
    }
@@ -38,4 +103,127 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompl
        return "", fmt.Errorf("no choices returned from OpenAI")
    }
    return resp.Choices[0].Message.Content, nil
-}
\ No newline at end of file
+}
+
+// ChatCompletionTools is like ChatCompletionUsage but offers tools to the
+// model. The reply is either the final answer or a request to call some of
+// the tools. With final set the model must answer without calling any.
+func (c *Client) ChatCompletionTools(ctx context.Context, messages []openai.ChatCompletionMessage, model string, tools []openai.Tool, final bool) (openai.ChatCompletionMessage, openai.Usage, error) {
+   req := openai.ChatCompletionRequest{
+       Model:    model,
+       Messages: messages,
+       Tools:    tools,
+   }
+   if final && len(tools) > 0 {
+       req.ToolChoice = "none"
+   }
+   resp, err := c.create(ctx, req)
+   if err != nil {
+       return openai.ChatCompletionMessage{}, openai.Usage{}, err
+   }
+   if len(resp.Choices) == 0 {
+       return openai.ChatCompletionMessage{}, openai.Usage{}, fmt.Errorf("no choices returned from OpenAI")
+   }
+   return resp.Choices[0].Message, resp.Usage, nil
+}
+
+// ChatCompletionStream is like ChatCompletionUsage but streams the reply,
+// calling onDelta with each piece of content as it arrives. It returns the
+// whole reply.
+func (c *Client) ChatCompletionStream(ctx context.Context, messages []openai.ChatCompletionMessage, model string, onDelta func(string)) (string, openai.Usage, error) {
+   req := openai.ChatCompletionRequest{
+       Model:         model,
+       Messages:      messages,
+       Stream:        true,
+       StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+   }
+   r, err := c.begin(ctx, req)
+   if err != nil {
+       return "", openai.Usage{}, err
+   }
+   content, usage, err := c.stream(ctx, req, onDelta)
+   if err := c.finish(ctx, req, r, usage, err); err != nil {
+       return "", openai.Usage{}, err
+   }
+   return content, usage, nil
+}
+
+// stream reads a streamed reply to req.
+func (c *Client) stream(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (string, openai.Usage, error) {
+   stream, err := c.c.CreateChatCompletionStream(ctx, req)
+   if err != nil {
+       return "", openai.Usage{}, err
+   }
+   defer stream.Close()
+   var (
+       content strings.Builder
+       usage   openai.Usage
+   )
+   for {
+       resp, err := stream.Recv()
+       if errors.Is(err, io.EOF) {
+           return content.String(), usage, nil
+       }
+       if err != nil {
+           return "", openai.Usage{}, err
+       }
+       // the last chunk carries the usage and no choices
+       if resp.Usage != nil {
+           usage = *resp.Usage
+       }
+       if len(resp.Choices) > 0 && resp.Choices[0].Delta.Content != "" {
+           content.WriteString(resp.Choices[0].Delta.Content)
+           onDelta(resp.Choices[0].Delta.Content)
+       }
+   }
+}
+
+// create sends req to the chat completions endpoint unless a budget is
+// exhausted, recording it in the audit log and the spend ledger.
+func (c *Client) create(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+   r, err := c.begin(ctx, req)
+   if err != nil {
+       return openai.ChatCompletionResponse{}, err
+   }
+   resp, err := c.c.CreateChatCompletion(ctx, req)
+   if err := c.finish(ctx, req, r, resp.Usage, err); err != nil {
+       return openai.ChatCompletionResponse{}, err
+   }
+   return resp, nil
+}
+
+// begin returns a BudgetError if req may not be sent, and otherwise the
+// reservation of its estimated spending, nil without budgets.
+func (c *Client) begin(ctx context.Context, req openai.ChatCompletionRequest) (*spendEntry, error) {
+   if c.budgets == nil {
+       return nil, nil
+   }
+   return c.budgets.reserve(ctx, req)
+}
+
+// finish records a request that was sent in the audit log and, in place of
+// its reservation r, in the spend ledger. It returns reqErr, the outcome of
+// the request, or the error of recording a successful request, which is
+// then reported as failed.
+func (c *Client) finish(ctx context.Context, req openai.ChatCompletionRequest, r *spendEntry, usage openai.Usage, reqErr error) error {
+   if reqErr != nil {
+       if c.budgets != nil {
+           c.budgets.release(r)
+       }
+       if c.audit != nil {
+           // the failure itself is what gets reported
+           _ = c.audit.record(ctx, req, nil, reqErr)
+       }
+       return reqErr
+   }
+   var err error
+   if c.budgets != nil {
+       err = c.budgets.add(r, req.Model, usage)
+   }
+   if c.audit != nil {
+       if aerr := c.audit.record(ctx, req, &usage, nil); aerr != nil && err == nil {
+           err = aerr
+       }
+   }
+   return err
+}
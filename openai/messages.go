@@ -0,0 +1,221 @@
+package openai
+
+import (
+   "encoding/base64"
+   "fmt"
+
+   openai "github.com/sashabaranov/go-openai"
+
+   "github.com/sergey-suslov/ai-notes/attach"
+   "github.com/sergey-suslov/ai-notes/redact"
+   "github.com/sergey-suslov/ai-notes/store"
+)
+
+// DefaultModel is the model used for chat and note generation.
+const DefaultModel = "gpt-4o-mini"
+
+// MessageRole maps a stored message's role onto an OpenAI chat role. Tool
+// results map onto the user role, as MessageText flattens them into text.
+func MessageRole(cm store.Message) string {
+   if cm.Role == "assistant" {
+       return openai.ChatMessageRoleAssistant
+   }
+   return openai.ChatMessageRoleUser
+}
+
+// MessageText returns the text of a stored message with attached files
+// inlined, images named and tool calls and results written out, for requests
+// that do not use tools or need the images.
+func MessageText(cm store.Message) string {
+   text := cm.Content + attach.Format(cm.Parts)
+   for _, p := range cm.Parts {
+       if p.Type == store.PartImage {
+           text += fmt.Sprintf("\n[image: %s]", p.Filename)
+       }
+   }
+   for _, call := range cm.ToolCalls {
+       text += fmt.Sprintf("\n[called tool %s with %s]", call.Name, call.Arguments)
+   }
+   if cm.Role == store.RoleTool {
+       text = fmt.Sprintf("[result of tool %s]\n%s", cm.Name, text)
+   }
+   return text
+}
+
+// SessionMessage converts a stored message into an OpenAI chat message,
+// inlining attached files into the content and sending images as
+// MultiContent parts. Tool calls and results become text.
+func SessionMessage(session *store.Session, cm store.Message) (openai.ChatCompletionMessage, error) {
+   role := MessageRole(cm)
+   text := MessageText(cm)
+   var images []openai.ChatMessagePart
+   for _, p := range cm.Parts {
+       if p.Type != store.PartImage {
+           continue
+       }
+       data, err := session.ReadImage(p)
+       if err != nil {
+           return openai.ChatCompletionMessage{}, err
+       }
+       images = append(images, openai.ChatMessagePart{
+           Type: openai.ChatMessagePartTypeImageURL,
+           ImageURL: &openai.ChatMessageImageURL{
+               URL:    "data:" + p.MimeType + ";base64," + base64.StdEncoding.EncodeToString(data),
+               Detail: openai.ImageURLDetailAuto,
+           },
+       })
+   }
+   if len(images) == 0 {
+       return openai.ChatCompletionMessage{Role: role, Content: text}, nil
+   }
+   // the API rejects messages that set both Content and MultiContent
+   multi := append([]openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: text}}, images...)
+   return openai.ChatCompletionMessage{Role: role, MultiContent: multi}, nil
+}
+
+// Sent reports whether a stored message is part of the conversation sent
+// to the provider. Status lines written by the app are not.
+func Sent(cm store.Message) bool {
+   return cm.Role != store.RoleStatus
+}
+
+// sent returns the messages of chat that are sent to the provider.
+func sent(chat []store.Message) []store.Message {
+   out := make([]store.Message, 0, len(chat))
+   for _, cm := range chat {
+       if Sent(cm) {
+           out = append(out, cm)
+       }
+   }
+   return out
+}
+
+// SessionMessages converts chat, messages of session, into OpenAI chat
+// messages, leaving out the messages that are not Sent.
+func SessionMessages(session *store.Session, chat []store.Message) ([]openai.ChatCompletionMessage, error) {
+   chat = sent(chat)
+   msgs := make([]openai.ChatCompletionMessage, len(chat))
+   for i, cm := range chat {
+       msg, err := SessionMessage(session, cm)
+       if err != nil {
+           return nil, err
+       }
+       msgs[i] = msg
+   }
+   return msgs, nil
+}
+
+// TextMessages is like SessionMessages but leaves images out, for requests
+// such as note generation that only need the text of the conversation.
+func TextMessages(chat []store.Message) []openai.ChatCompletionMessage {
+   chat = sent(chat)
+   msgs := make([]openai.ChatCompletionMessage, len(chat))
+   for i, cm := range chat {
+       msgs[i] = openai.ChatCompletionMessage{Role: MessageRole(cm), Content: MessageText(cm)}
+   }
+   return msgs
+}
+
+// ChatMessages is like SessionMessages but keeps tool calls and their
+// results as such, for requests offering tools. Calls whose results are not
+// all recorded, as when the app quit while one was waiting for
+// confirmation, are sent as text, since the API rejects them otherwise.
+func ChatMessages(session *store.Session, chat []store.Message) ([]openai.ChatCompletionMessage, error) {
+   chat = sent(chat)
+   msgs, err := SessionMessages(session, chat)
+   if err != nil {
+       return nil, err
+   }
+   for i := 0; i < len(chat); i++ {
+       cm := chat[i]
+       if len(cm.ToolCalls) == 0 || !answered(chat, i) {
+           continue
+       }
+       calls := make([]openai.ToolCall, len(cm.ToolCalls))
+       for j, call := range cm.ToolCalls {
+           calls[j] = openai.ToolCall{
+               ID:       call.ID,
+               Type:     openai.ToolTypeFunction,
+               Function: openai.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+           }
+       }
+       msgs[i] = openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: cm.Content, ToolCalls: calls}
+       for j := range cm.ToolCalls {
+           result := chat[i+1+j]
+           msgs[i+1+j] = openai.ChatCompletionMessage{
+               Role:       openai.ChatMessageRoleTool,
+               Content:    result.Content,
+               Name:       result.Name,
+               ToolCallID: result.ToolCallID,
+           }
+       }
+       i += len(cm.ToolCalls)
+   }
+   return msgs, nil
+}
+
+// answered reports whether the tool calls of chat[i] are followed by their
+// results, in order.
+func answered(chat []store.Message, i int) bool {
+   calls := chat[i].ToolCalls
+   if i+len(calls) >= len(chat) {
+       return false
+   }
+   for j, call := range calls {
+       if r := chat[i+1+j]; r.Role != store.RoleTool || r.ToolCallID != call.ID {
+           return false
+       }
+   }
+   return true
+}
+
+// RedactMessages replaces secrets and personal data in the text of msgs,
+// in place, and returns the redactor to restore the reply with and the
+// redactions of each message.
+func RedactMessages(rules []redact.Rule, msgs []openai.ChatCompletionMessage) (*redact.Redactor, [][]store.Redaction) {
+   r := redact.New(rules)
+   log := make([][]store.Redaction, len(msgs))
+   for i := range msgs {
+       var matches []redact.Match
+       msgs[i].Content, matches = r.Redact(msgs[i].Content)
+       log[i] = appendRedactions(log[i], matches)
+       for j := range msgs[i].ToolCalls {
+           msgs[i].ToolCalls[j].Function.Arguments, matches = r.Redact(msgs[i].ToolCalls[j].Function.Arguments)
+           log[i] = appendRedactions(log[i], matches)
+       }
+       for j := range msgs[i].MultiContent {
+           msgs[i].MultiContent[j].Text, matches = r.Redact(msgs[i].MultiContent[j].Text)
+           log[i] = appendRedactions(log[i], matches)
+       }
+   }
+   return r, log
+}
+
+// SetRedactions records the redaction log of a request, as returned by
+// RedactMessages, on the Sent messages of chat it was built from.
+func SetRedactions(chat []store.Message, log [][]store.Redaction) {
+   j := 0
+   for i := range chat {
+       if j == len(log) {
+           return
+       }
+       if Sent(chat[i]) {
+           chat[i].Redactions = log[j]
+           j++
+       }
+   }
+}
+
+// appendRedactions logs matches, once per placeholder.
+func appendRedactions(log []store.Redaction, matches []redact.Match) []store.Redaction {
+   for _, m := range matches {
+       seen := false
+       for _, l := range log {
+           seen = seen || l.Placeholder == m.Placeholder
+       }
+       if !seen {
+           log = append(log, store.Redaction{Rule: m.Rule, Placeholder: m.Placeholder})
+       }
+   }
+   return log
+}
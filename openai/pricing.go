@@ -0,0 +1,34 @@
+package openai
+
+import (
+   openai "github.com/sashabaranov/go-openai"
+)
+
+// price is the cost in USD per million prompt and completion tokens.
+type price struct {
+   prompt, completion float64
+}
+
+// prices lists known model prices. Unknown models cost nothing by Cost, so
+// budgets warn about them; see Priced.
+var prices = map[string]price{
+   "gpt-4o-mini":   {prompt: 0.15, completion: 0.60},
+   "gpt-4o":        {prompt: 2.50, completion: 10.00},
+   "gpt-4.1":       {prompt: 2.00, completion: 8.00},
+   "gpt-4.1-mini":  {prompt: 0.40, completion: 1.60},
+   "gpt-4.1-nano":  {prompt: 0.10, completion: 0.40},
+   "gpt-3.5-turbo": {prompt: 0.50, completion: 1.50},
+}
+
+// Priced reports whether the price of model is known.
+func Priced(model string) bool {
+   _, ok := prices[model]
+   return ok
+}
+
+// Cost returns the cost in USD of a request to model with the given usage,
+// 0 if its price is not known.
+func Cost(model string, u openai.Usage) float64 {
+   p := prices[model]
+   return (float64(u.PromptTokens)*p.prompt + float64(u.CompletionTokens)*p.completion) / 1e6
+}
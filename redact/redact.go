@@ -0,0 +1,130 @@
+// Package redact replaces secrets and personal data in text sent to the
+// provider with placeholders, and restores the originals in replies.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sergey-suslov/ai-notes/config"
+)
+
+// Rule is a named pattern whose matches are redacted. The name becomes part
+// of the placeholder, e.g. [EMAIL_1].
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules detect common secrets and personal data. Rules are applied
+// in order, so the more specific ones come first.
+var DefaultRules = []Rule{
+	{"PRIVATE_KEY", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"API_KEY", regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abposr]-[A-Za-z0-9-]{10,})\b`)},
+	{"TOKEN", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+|(?i:bearer)\s+[A-Za-z0-9._~+/-]{20,}=*`)},
+	{"EMAIL", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{"PHONE", regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b`)},
+}
+
+// Rules returns the default rules except those named in disabled, followed
+// by the custom patterns, keyed by name. Names are upper-cased.
+func Rules(custom map[string]string, disabled []string) ([]Rule, error) {
+	off := make(map[string]bool)
+	for _, name := range disabled {
+		off[strings.ToUpper(name)] = true
+	}
+	var rules []Rule
+	for _, r := range DefaultRules {
+		if !off[r.Name] {
+			rules = append(rules, r)
+		}
+	}
+	// sorted so placeholders do not depend on map order
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(custom[name])
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %s: %w", name, err)
+		}
+		rules = append(rules, Rule{Name: strings.ToUpper(name), Pattern: re})
+	}
+	return rules, nil
+}
+
+// FromConfig returns the rules configured by c, or nil if redaction is
+// disabled.
+func FromConfig(c config.Redact) ([]Rule, error) {
+	if c.Disabled {
+		return nil, nil
+	}
+	return Rules(c.Patterns, c.Skip)
+}
+
+// Match is one redacted value.
+type Match struct {
+	Rule        string
+	Placeholder string
+	Value       string
+}
+
+// Redactor redacts texts with a set of rules. The same value always gets the
+// same placeholder, so the texts of one request stay consistent and the
+// reply can be restored.
+type Redactor struct {
+	rules        []Rule
+	placeholders map[string]string // value -> placeholder
+	values       map[string]string // placeholder -> value
+	counts       map[string]int    // rule name -> placeholders issued
+}
+
+// New returns a Redactor applying rules.
+func New(rules []Rule) *Redactor {
+	return &Redactor{
+		rules:        rules,
+		placeholders: make(map[string]string),
+		values:       make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// Redact replaces every match in text with its placeholder and returns the
+// redacted text and the matches, in order of the rules.
+func (r *Redactor) Redact(text string) (string, []Match) {
+	var matches []Match
+	for _, rule := range r.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(value string) string {
+			// never redact a placeholder issued by an earlier rule
+			if _, ok := r.values[value]; ok {
+				return value
+			}
+			p, ok := r.placeholders[value]
+			if !ok {
+				r.counts[rule.Name]++
+				p = fmt.Sprintf("[%s_%d]", rule.Name, r.counts[rule.Name])
+				r.placeholders[value] = p
+				r.values[p] = value
+			}
+			matches = append(matches, Match{Rule: rule.Name, Placeholder: p, Value: value})
+			return p
+		})
+	}
+	return text, matches
+}
+
+// Restore replaces the placeholders in text with the values they stand for.
+func (r *Redactor) Restore(text string) string {
+	if len(r.values) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(r.values))
+	for p, v := range r.values {
+		pairs = append(pairs, p, v)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
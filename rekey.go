@@ -0,0 +1,98 @@
+package main
+
+import (
+   "errors"
+   "flag"
+   "fmt"
+   "os"
+
+   "golang.org/x/term"
+
+   "github.com/sergey-suslov/ai-notes/store"
+)
+
+// passphraseEnv names the environment variable that supplies the store
+// passphrase instead of the terminal prompt, for scripts.
+const passphraseEnv = "AI_NOTES_PASSPHRASE"
+
+// unlock asks for the passphrase of an encrypted store once per run.
+func unlock() error {
+   if store.Unlocked() {
+       return nil
+   }
+   if pass, ok := os.LookupEnv(passphraseEnv); ok {
+       return store.Unlock(pass)
+   }
+   if store.RekeyPending() {
+       fmt.Fprintln(os.Stderr, "A rekey was interrupted; it is finished once the store is unlocked with the old or the new passphrase.")
+   }
+   for tries := 0; ; tries++ {
+       pass, err := readPassphrase("Passphrase: ")
+       if err != nil {
+           return err
+       }
+       err = store.Unlock(pass)
+       if !errors.Is(err, store.ErrPassphrase) || tries == 2 {
+           return err
+       }
+       fmt.Fprintln(os.Stderr, "Wrong passphrase, try again.")
+   }
+}
+
+// readPassphrase prompts on stderr and reads a line from the terminal
+// without echoing it.
+func readPassphrase(prompt string) (string, error) {
+   fmt.Fprint(os.Stderr, prompt)
+   pass, err := term.ReadPassword(int(os.Stdin.Fd()))
+   fmt.Fprintln(os.Stderr)
+   if err != nil {
+       return "", fmt.Errorf("reading passphrase: %w", err)
+   }
+   return string(pass), nil
+}
+
+// runRekey implements "ai-notes rekey": it encrypts the store with a new
+// passphrase, changes the passphrase of an encrypted store, or with -decrypt
+// turns encryption off.
+func runRekey(args []string) error {
+   fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+   decrypt := fs.Bool("decrypt", false, "remove encryption and store files as plaintext")
+   fs.Usage = func() {
+       fmt.Fprintf(fs.Output(), "usage: ai-notes rekey [-decrypt]\n\n")
+       fs.PrintDefaults()
+   }
+   if err := fs.Parse(args); err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           return nil
+       }
+       return err
+   }
+   pass := ""
+   if !*decrypt {
+       var err error
+       if pass, err = readPassphrase("New passphrase: "); err != nil {
+           return err
+       }
+       if pass == "" {
+           return fmt.Errorf("empty passphrase; use -decrypt to remove encryption")
+       }
+       again, err := readPassphrase("Repeat passphrase: ")
+       if err != nil {
+           return err
+       }
+       if again != pass {
+           return fmt.Errorf("passphrases do not match")
+       }
+   } else if !store.Encrypted() {
+       return fmt.Errorf("the store is not encrypted")
+   }
+   if err := store.Rekey(pass); err != nil {
+       return err
+   }
+   if *decrypt {
+       fmt.Println("store decrypted")
+   } else {
+       fmt.Println("store encrypted with the new passphrase")
+   }
+   return nil
+}
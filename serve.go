@@ -0,0 +1,59 @@
+package main
+
+import (
+   "errors"
+   "flag"
+   "fmt"
+   "net/http"
+   "os"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   openaiclient "github.com/sergey-suslov/ai-notes/openai"
+   "github.com/sergey-suslov/ai-notes/redact"
+   "github.com/sergey-suslov/ai-notes/server"
+)
+
+// tokenEnv names the environment variable that sets the server token.
+const tokenEnv = "AI_NOTES_TOKEN"
+
+// runServe implements "ai-notes serve": it serves the REST API on a local
+// address until interrupted.
+func runServe(args []string) error {
+   fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+   addr := fs.String("addr", "127.0.0.1:8765", "address to listen on")
+   fs.Usage = func() {
+       fmt.Fprintf(fs.Output(), "usage: ai-notes serve [-addr HOST:PORT]\n\n")
+       fmt.Fprintf(fs.Output(), "Requests must send the token from $%s or ~/.ai-notes/server-token\nas \"Authorization: Bearer TOKEN\".\n\n", tokenEnv)
+       fs.PrintDefaults()
+   }
+   if err := fs.Parse(args); err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           return nil
+       }
+       return err
+   }
+   cfg, err := config.Load()
+   if err != nil {
+       return fmt.Errorf("loading config: %w", err)
+   }
+   token := os.Getenv(tokenEnv)
+   if token == "" {
+       if token, err = server.LoadToken(); err != nil {
+           return err
+       }
+   }
+   client, err := openaiclient.NewClientFromConfig(cfg)
+   if err != nil {
+       return err
+   }
+   rules, err := redact.FromConfig(cfg.Redact)
+   if err != nil {
+       return fmt.Errorf("loading config: %w", err)
+   }
+   srv, err := server.New(server.Config{Client: client, Token: token, Vaults: cfg.Vaults, Rules: rules})
+   if err != nil {
+       return err
+   }
+   fmt.Fprintf(os.Stderr, "serving the ai-notes API on http://%s\n", *addr)
+   return http.ListenAndServe(*addr, srv.Handler())
+}
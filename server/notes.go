@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// noteJSON is the API representation of a note.
+type noteJSON struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Markdown  string    `json:"markdown"` // body with the structured fields as sections
+	SessionID string    `json:"session_id,omitempty"`
+	Notebook  string    `json:"notebook,omitempty"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	// ReadOnly is set for notes indexed from a vault
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// toJSON returns the API representation of n.
+func toJSON(n *store.Note) noteJSON {
+	tags := n.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return noteJSON{
+		ID:        n.ID,
+		Title:     n.Title,
+		Body:      n.Body,
+		Markdown:  n.Markdown(),
+		SessionID: n.SessionID,
+		Notebook:  n.Notebook,
+		Tags:      tags,
+		CreatedAt: n.CreatedAt,
+		ReadOnly:  n.External != "",
+	}
+}
+
+// listNotes lists the notes matching the "q" query, the "tag" and the
+// "notebook" (including its sub-notebooks), newest first.
+func (s *Server) listNotes(w http.ResponseWriter, r *http.Request) {
+	notes, err := store.LoadAllNotes(s.cfg.Vaults)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	query := r.URL.Query()
+	if nb := query.Get("notebook"); nb != "" {
+		notes = store.FilterNotebook(notes, store.NormalizeNotebook(nb))
+	}
+	tag := strings.ToLower(query.Get("tag"))
+	out := []noteJSON{}
+	for _, n := range notes {
+		if (tag == "" || n.HasTag(tag)) && n.Matches(query.Get("q")) {
+			out = append(out, toJSON(n))
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// getNote returns a note, which may be one indexed from a vault.
+func (s *Server) getNote(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !validID(id) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid note ID %q", id))
+		return
+	}
+	notes, err := store.LoadAllNotes(s.cfg.Vaults)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, n := range notes {
+		if n.ID == id {
+			writeJSON(w, http.StatusOK, toJSON(n))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, errors.New("note not found"))
+}
+
+// createNote saves a new note.
+func (s *Server) createNote(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title     string   `json:"title"`
+		Body      string   `json:"body"`
+		Tags      []string `json:"tags"`
+		Notebook  string   `json:"notebook"`
+		SessionID string   `json:"session_id"`
+	}
+	if err := decode(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("title is empty"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notes, err := store.LoadNotes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	n := store.NewNote(req.SessionID, req.Body)
+	n.Title = req.Title
+	n.Notebook = store.NormalizeNotebook(req.Notebook)
+	n.Tags = store.NormalizeTags(req.Tags, store.TagVocabulary(notes))
+	if _, err := n.Save(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toJSON(n))
+}
@@ -0,0 +1,191 @@
+// Package server exposes sessions, notes and chat over a local REST/JSON
+// API, so other tools can use the same notes repository as the TUI.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	goopenai "github.com/sashabaranov/go-openai"
+
+	openaiclient "github.com/sergey-suslov/ai-notes/openai"
+	"github.com/sergey-suslov/ai-notes/redact"
+)
+
+// Chatter is the part of the provider client used for chat.
+// *openai.Client implements it; tests can substitute a fake.
+type Chatter interface {
+	ChatCompletionStream(ctx context.Context, messages []goopenai.ChatCompletionMessage, model string, onDelta func(string)) (string, goopenai.Usage, error)
+}
+
+// budgetWarner is implemented by clients that warn as budgets run low, as
+// *openai.Client does.
+type budgetWarner interface {
+	TakeBudgetWarnings() []string
+}
+
+// Config configures a Server.
+type Config struct {
+	Client Chatter
+	// Token must be presented by every request, as a bearer token or in
+	// the "token" query parameter for clients such as EventSource.
+	Token string
+	// Model is the chat model, openai.DefaultModel if "".
+	Model string
+	// Vaults are indexed markdown folders listed along with the notes.
+	Vaults []string
+	// Rules select what is redacted from chat requests; nil disables
+	// redaction.
+	Rules []redact.Rule
+}
+
+const tokenFileName = "server-token"
+
+// LoadToken returns the token kept in ~/.ai-notes/server-token, creating a
+// random one on first use. Local tools read the file to authenticate.
+func LoadToken() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %w", err)
+	}
+	path := filepath.Join(home, ".ai-notes", tokenFileName)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading server token: %w", err)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generating server token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("creating config dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("writing server token: %w", err)
+	}
+	return token, nil
+}
+
+// Server serves the API.
+type Server struct {
+	cfg Config
+	// mu serializes changes to sessions and notes, and guards chats
+	mu sync.Mutex
+	// chats holds a lock per session ID, held while a reply is streamed
+	chats map[string]*sync.Mutex
+}
+
+// New returns a Server for cfg.
+func New(cfg Config) (*Server, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("server token is empty")
+	}
+	if cfg.Model == "" {
+		cfg.Model = openaiclient.DefaultModel
+	}
+	return &Server{cfg: cfg, chats: make(map[string]*sync.Mutex)}, nil
+}
+
+// chatLock returns the lock serializing chats in the session id.
+func (s *Server) chatLock(id string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.chats[id]
+	if !ok {
+		l = new(sync.Mutex)
+		s.chats[id] = l
+	}
+	return l
+}
+
+// Handler returns the HTTP handler of the API:
+//
+//	GET  /api/sessions?q=                     list sessions, optionally searched
+//	POST /api/sessions                        create a session
+//	GET  /api/sessions/{id}                   a session with its messages
+//	POST /api/sessions/{id}/messages          send a chat message; the reply is streamed as SSE
+//	GET  /api/notes?q=&tag=&notebook=         list notes, optionally searched or filtered
+//	POST /api/notes                           create a note
+//	GET  /api/notes/{id}                      a note
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/sessions", s.listSessions)
+	mux.HandleFunc("POST /api/sessions", s.createSession)
+	mux.HandleFunc("GET /api/sessions/{id}", s.getSession)
+	mux.HandleFunc("POST /api/sessions/{id}/messages", s.postMessage)
+	mux.HandleFunc("GET /api/notes", s.listNotes)
+	mux.HandleFunc("POST /api/notes", s.createNote)
+	mux.HandleFunc("GET /api/notes/{id}", s.getNote)
+	return s.authorize(mux)
+}
+
+// authorize rejects requests that do not carry the server token.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as the JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// writeError writes err as a JSON error response.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// loadError maps an error loading a stored item onto a response.
+func loadError(w http.ResponseWriter, kind string, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", kind))
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+// decode reads the JSON request body into v. An empty body leaves v as it is.
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// validID reports whether id can name a stored file, so path parameters
+// cannot reach outside the store.
+func validID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	goopenai "github.com/sashabaranov/go-openai"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+const testToken = "secret"
+
+// fakeChatter streams deltas as the reply, or fails with err, and then
+// raises warnings. If wait is set, each request reports on started and then
+// blocks until wait is closed.
+type fakeChatter struct {
+	deltas   []string
+	err      error
+	warnings []string
+	started  chan string
+	wait     chan struct{}
+}
+
+func (f *fakeChatter) TakeBudgetWarnings() []string {
+	if len(f.warnings) == 0 {
+		return nil
+	}
+	w := f.warnings
+	f.warnings = nil
+	return w
+}
+
+func (f *fakeChatter) ChatCompletionStream(ctx context.Context, messages []goopenai.ChatCompletionMessage, model string, onDelta func(string)) (string, goopenai.Usage, error) {
+	if f.wait != nil {
+		f.started <- messages[len(messages)-1].Content
+		select {
+		case <-f.wait:
+		case <-ctx.Done():
+			return "", goopenai.Usage{}, ctx.Err()
+		}
+	}
+	if f.err != nil {
+		return "", goopenai.Usage{}, f.err
+	}
+	for _, d := range f.deltas {
+		onDelta(d)
+	}
+	return strings.Join(f.deltas, ""), goopenai.Usage{PromptTokens: 10, CompletionTokens: 5}, nil
+}
+
+// newTestServer serves the API for chatter over a store in a temporary
+// home directory.
+func newTestServer(t *testing.T, chatter Chatter) *httptest.Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	s, err := New(Config{Client: chatter, Token: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// do sends an authorized request and returns the response.
+func do(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// sseEvent is one server-sent event.
+type sseEvent struct {
+	name string
+	data map[string]any
+}
+
+// parseEvents reads the events of an SSE stream until it ends.
+func parseEvents(r io.Reader) ([]sseEvent, error) {
+	var events []sseEvent
+	var ev sseEvent
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				return nil, fmt.Errorf("event %s: %w", ev.name, err)
+			}
+		case line == "":
+			events = append(events, ev)
+			ev = sseEvent{}
+		}
+	}
+	return events, sc.Err()
+}
+
+// readEvents reads the events of an SSE response until it ends.
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+	events, err := parseEvents(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// saveSession stores an empty session with the given ID.
+func saveSession(t *testing.T, id string) {
+	t.Helper()
+	sess := store.NewSession()
+	sess.ID = id
+	if err := sess.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ts := newTestServer(t, &fakeChatter{})
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"bearer", "Bearer " + testToken, "", http.StatusOK},
+		{"wrong bearer", "Bearer nope", "", http.StatusUnauthorized},
+		{"bearer prefix", "Bearer " + testToken[:3], "", http.StatusUnauthorized},
+		{"not bearer", "Basic " + testToken, "", http.StatusUnauthorized},
+		{"query", "", "?token=" + testToken, http.StatusOK},
+		{"wrong query", "", "?token=nope", http.StatusUnauthorized},
+		{"empty query", "", "?token=", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/sessions"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want Bearer", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"20240102T150405", true},
+		{"20240102T150405-2", true},
+		{"..hidden", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../key", false},
+		{"a/b", false},
+		{`..\key`, false},
+		{"/etc/passwd", false},
+	}
+	for _, tt := range tests {
+		if got := validID(tt.id); got != tt.want {
+			t.Errorf("validID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestTraversalRejected(t *testing.T) {
+	ts := newTestServer(t, &fakeChatter{})
+	for _, path := range []string{
+		"/api/sessions/..%2Fkey",
+		"/api/sessions/..%5Ckey",
+		"/api/notes/..%2F..%2Fkey",
+		"/api/notes/..%5Ckey",
+	} {
+		resp := do(t, http.MethodGet, ts.URL+path, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want %d", path, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+	resp := do(t, http.MethodPost, ts.URL+"/api/sessions/..%2Fkey/messages", `{"content":"hi"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST message: status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestCreateSessionsInOneSecond(t *testing.T) {
+	ts := newTestServer(t, &fakeChatter{})
+	ids := make(map[string]bool)
+	for i := range 3 {
+		resp := do(t, http.MethodPost, ts.URL+"/api/sessions", fmt.Sprintf(`{"title":"s%d"}`, i))
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+		}
+		var sess store.Session
+		if err := json.NewDecoder(resp.Body).Decode(&sess); err != nil {
+			t.Fatal(err)
+		}
+		if ids[sess.ID] {
+			t.Fatalf("session ID %s returned twice", sess.ID)
+		}
+		ids[sess.ID] = true
+	}
+	sessions, err := store.LoadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Errorf("%d sessions saved, want 3", len(sessions))
+	}
+}
+
+func TestPostMessageStream(t *testing.T) {
+	ts := newTestServer(t, &fakeChatter{deltas: []string{"Hel", "lo, ", "world"}})
+	saveSession(t, "s1")
+
+	resp := do(t, http.MethodPost, ts.URL+"/api/sessions/s1/messages", `{"content":"hi"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	events := readEvents(t, resp)
+	if len(events) == 0 || events[len(events)-1].name != "done" {
+		t.Fatalf("events = %v, want deltas then done", events)
+	}
+	var reply string
+	for _, ev := range events[:len(events)-1] {
+		if ev.name != "delta" {
+			t.Fatalf("event %q before done, want only deltas", ev.name)
+		}
+		reply += ev.data["content"].(string)
+	}
+	if reply != "Hello, world" {
+		t.Errorf("deltas = %q, want %q", reply, "Hello, world")
+	}
+	msg, _ := events[len(events)-1].data["message"].(map[string]any)
+	if msg["content"] != "Hello, world" {
+		t.Errorf("done message = %v, want the whole reply", msg)
+	}
+
+	sess, err := store.LoadSession("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.Chat) != 2 || sess.Chat[0].Content != "hi" || sess.Chat[1].Content != "Hello, world" {
+		t.Errorf("saved chat = %+v, want the message and the reply", sess.Chat)
+	}
+	if sess.Usage.PromptTokens != 10 || sess.Usage.CompletionTokens != 5 {
+		t.Errorf("saved usage = %+v, want 10+5 tokens", sess.Usage)
+	}
+}
+
+func TestPostMessageStreamWarnings(t *testing.T) {
+	ts := newTestServer(t, &fakeChatter{deltas: []string{"ok"}, warnings: []string{"80% of the daily budget of $1.00 used ($0.80)"}})
+	saveSession(t, "s1")
+
+	events := readEvents(t, do(t, http.MethodPost, ts.URL+"/api/sessions/s1/messages", `{"content":"hi"}`))
+	var names []string
+	for _, ev := range events {
+		names = append(names, ev.name)
+	}
+	if fmt.Sprint(names) != "[delta warning done]" {
+		t.Fatalf("events = %v, want [delta warning done]", names)
+	}
+	if got := events[1].data["warning"]; got != "80% of the daily budget of $1.00 used ($0.80)" {
+		t.Errorf("warning = %v", got)
+	}
+}
+
+func TestPostMessageStreamError(t *testing.T) {
+	ts := newTestServer(t, &fakeChatter{err: errors.New("provider down")})
+	saveSession(t, "s1")
+
+	events := readEvents(t, do(t, http.MethodPost, ts.URL+"/api/sessions/s1/messages", `{"content":"hi"}`))
+	if len(events) != 1 || events[0].name != "error" || events[0].data["error"] != "provider down" {
+		t.Fatalf("events = %v, want one error event", events)
+	}
+	sess, err := store.LoadSession("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.Chat) != 0 {
+		t.Errorf("saved chat = %+v, want it unchanged", sess.Chat)
+	}
+}
+
+func TestPostMessageRejectsBadRequests(t *testing.T) {
+	ts := newTestServer(t, &fakeChatter{})
+	saveSession(t, "s1")
+	tests := []struct {
+		name, path, body string
+		want             int
+	}{
+		{"empty content", "/api/sessions/s1/messages", `{"content":"  "}`, http.StatusBadRequest},
+		{"unknown field", "/api/sessions/s1/messages", `{"text":"hi"}`, http.StatusBadRequest},
+		{"missing session", "/api/sessions/nope/messages", `{"content":"hi"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := do(t, http.MethodPost, ts.URL+tt.path, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostMessageSessionsStreamInParallel(t *testing.T) {
+	f := &fakeChatter{deltas: []string{"ok"}, started: make(chan string, 2), wait: make(chan struct{})}
+	ts := newTestServer(t, f)
+	saveSession(t, "a")
+	saveSession(t, "b")
+
+	done := make(chan []sseEvent, 2)
+	for _, id := range []string{"a", "b"} {
+		go func() {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/sessions/"+id+"/messages", strings.NewReader(`{"content":"`+id+`"}`))
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				done <- nil
+				return
+			}
+			defer resp.Body.Close()
+			events, _ := parseEvents(resp.Body)
+			done <- events
+		}()
+	}
+	// both streams have to be under way at once
+	for range 2 {
+		select {
+		case <-f.started:
+		case <-time.After(2 * time.Second):
+			close(f.wait)
+			t.Fatal("a stream in one session blocked a chat in another")
+		}
+	}
+	close(f.wait)
+	for range 2 {
+		events := <-done
+		if len(events) == 0 || events[len(events)-1].name != "done" {
+			t.Errorf("events = %v, want the reply", events)
+		}
+	}
+}
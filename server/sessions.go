@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	openaiclient "github.com/sergey-suslov/ai-notes/openai"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// sessionSummary is a session in listings, without its messages.
+type sessionSummary struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Notebook    string    `json:"notebook,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Messages    int       `json:"messages"`
+}
+
+// summarizeSession returns the listing entry of s.
+func summarizeSession(s *store.Session) sessionSummary {
+	return sessionSummary{
+		ID:          s.ID,
+		Title:       s.DisplayTitle(),
+		Description: s.Description,
+		Notebook:    s.Notebook,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.LastUpdated(),
+		Messages:    len(s.Chat),
+	}
+}
+
+// listSessions lists the sessions matching the "q" query, newest first.
+func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := store.LoadSessions()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	q := r.URL.Query().Get("q")
+	out := []sessionSummary{}
+	for _, sess := range sessions {
+		if sess.Matches(q) {
+			out = append(out, summarizeSession(sess))
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// createSession creates an empty session.
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title    string `json:"title"`
+		Notebook string `json:"notebook"`
+	}
+	if err := decode(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// the ID is unique among saved sessions, so it is picked and saved
+	// under the lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := store.NewSession()
+	sess.Title = req.Title
+	sess.Notebook = store.NormalizeNotebook(req.Notebook)
+	if err := sess.Save(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+// getSession returns a session with its messages.
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !validID(id) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid session ID %q", id))
+		return
+	}
+	sess, err := store.LoadSession(id)
+	if err != nil {
+		loadError(w, "session", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+// postMessage adds a user message to a session and streams the reply as
+// server-sent events: "delta" events carry pieces of the reply, "warning"
+// events budgets running low, then a "done" event carries the saved reply,
+// or an "error" event the failure.
+func (s *Server) postMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !validID(id) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid session ID %q", id))
+		return
+	}
+	var req struct {
+		Content string `json:"content"`
+		// OverrideBudget sends the message even if a budget is exhausted.
+		OverrideBudget bool `json:"override_budget"`
+	}
+	if err := decode(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("content is empty"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	// one chat per session at a time, so concurrent messages cannot
+	// overwrite each other; other sessions are not held up by the stream
+	l := s.chatLock(id)
+	l.Lock()
+	defer l.Unlock()
+	sess, err := store.LoadSession(id)
+	if err != nil {
+		loadError(w, "session", err)
+		return
+	}
+	sess.Chat = append(sess.Chat, store.Message{Role: "user", Content: req.Content})
+	msgs, err := openaiclient.SessionMessages(sess, sess.Chat)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	redactor, redactions := openaiclient.RedactMessages(s.cfg.Rules, msgs)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	event := func(name string, v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+		flusher.Flush()
+	}
+
+	ctx := openaiclient.WithSession(r.Context(), sess.ID)
+	if req.OverrideBudget {
+		ctx = openaiclient.WithBudgetOverride(ctx)
+	}
+	// placeholders may be split across deltas, so text from an unclosed
+	// "[" on is held back until the rest arrives
+	var pending string
+	reply, usage, err := s.cfg.Client.ChatCompletionStream(ctx, msgs, s.cfg.Model, func(delta string) {
+		pending += delta
+		cut := len(pending)
+		if open := strings.LastIndex(pending, "["); open >= 0 && !strings.Contains(pending[open:], "]") {
+			cut = open
+		}
+		if cut > 0 {
+			event("delta", map[string]string{"content": redactor.Restore(pending[:cut])})
+			pending = pending[cut:]
+		}
+	})
+	if bw, ok := s.cfg.Client.(budgetWarner); ok {
+		for _, warning := range bw.TakeBudgetWarnings() {
+			event("warning", map[string]string{"warning": warning})
+		}
+	}
+	if err != nil {
+		body := map[string]any{"error": err.Error()}
+		if be := (*openaiclient.BudgetError)(nil); errors.As(err, &be) {
+			body["budget_exhausted"] = true
+		}
+		event("error", body)
+		return
+	}
+	if pending != "" {
+		event("delta", map[string]string{"content": redactor.Restore(pending)})
+	}
+	openaiclient.SetRedactions(sess.Chat, redactions)
+	msg := store.Message{Role: "assistant", Content: redactor.Restore(reply)}
+	sess.Chat = append(sess.Chat, msg)
+	sess.UpdatedAt = time.Now()
+	sess.Usage.Add(store.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             openaiclient.Cost(s.cfg.Model, usage),
+	})
+	if err := sess.Save(); err != nil {
+		event("error", map[string]string{"error": err.Error()})
+		return
+	}
+	event("done", map[string]any{"message": msg, "usage": sess.Usage})
+}
@@ -0,0 +1,443 @@
+package store
+
+import (
+   "bytes"
+   "crypto/aes"
+   "crypto/cipher"
+   "crypto/rand"
+   "encoding/json"
+   "errors"
+   "fmt"
+   "io"
+   "io/fs"
+   "os"
+   "path/filepath"
+   "strings"
+
+   "golang.org/x/crypto/scrypt"
+)
+
+const (
+   keyFileName = "key.json"
+
+   // scrypt cost parameters for new keys
+   scryptN = 1 << 15
+   scryptR = 8
+   scryptP = 1
+
+   // permissions for everything written under ~/.ai-notes
+   filePerm = 0o600
+   dirPerm  = 0o700
+)
+
+// encMagic starts every encrypted file; it is followed by the AES-GCM nonce
+// and the sealed content.
+var encMagic = []byte("AINOTES-ENC1\n")
+
+var (
+   // ErrLocked is returned when reading or writing an encrypted store
+   // before Unlock was called.
+   ErrLocked = errors.New("store is encrypted: unlock it first")
+   // ErrPassphrase is returned by Unlock for a wrong passphrase.
+   ErrPassphrase = errors.New("wrong passphrase")
+)
+
+// dataKey is the AES-256 key of an unlocked store, nil if the store is not
+// encrypted or still locked. It is set once per run by Unlock.
+var dataKey []byte
+
+// oldKey is the key being replaced while Rekey runs. Files not rewritten yet
+// are still sealed with it.
+var oldKey []byte
+
+// keyParams holds the scrypt parameters of a key and a check value used to
+// verify a passphrase; the key itself is never stored in the clear.
+type keyParams struct {
+   Salt  []byte `json:"salt,omitempty"`
+   N     int    `json:"n,omitempty"`
+   R     int    `json:"r,omitempty"`
+   P     int    `json:"p,omitempty"`
+   Check []byte `json:"check,omitempty"` // encMagic sealed with the derived key
+}
+
+// keyFile is ~/.ai-notes/key.json. Its parameters are those of the current
+// key; they are empty while a plaintext store is being encrypted.
+type keyFile struct {
+   keyParams
+   // Pending is set while a Rekey is under way, so that an interrupted one
+   // can be finished by the next Unlock.
+   Pending *pendingKey `json:"pending,omitempty"`
+}
+
+// pendingKey describes the key a Rekey is moving the store to. The two keys
+// are sealed with each other, so either passphrase recovers both.
+type pendingKey struct {
+   // Key holds the parameters of the new key, nil when decrypting.
+   Key *keyParams `json:"key,omitempty"`
+   // NewKey is the new key sealed with the current one.
+   NewKey []byte `json:"new_key,omitempty"`
+   // OldKey is the current key sealed with the new one.
+   OldKey []byte `json:"old_key,omitempty"`
+}
+
+// keyFilePath returns the location of the key file (~/.ai-notes/key.json).
+func keyFilePath() (string, error) {
+   base, err := baseDir()
+   if err != nil {
+       return "", err
+   }
+   return filepath.Join(base, keyFileName), nil
+}
+
+// Encrypted reports whether sessions and notes are encrypted at rest.
+func Encrypted() bool {
+   path, err := keyFilePath()
+   if err != nil {
+       return false
+   }
+   _, err = os.Stat(path)
+   return err == nil
+}
+
+// Unlocked reports whether the store can be read and written: it is not
+// encrypted, or Unlock succeeded.
+func Unlocked() bool {
+   return dataKey != nil || !Encrypted()
+}
+
+// RekeyPending reports whether a Rekey was interrupted. The next Unlock
+// finishes it.
+func RekeyPending() bool {
+   kf, err := readKeyFile()
+   return err == nil && kf.Pending != nil
+}
+
+// Unlock derives the store key from passphrase. It must be called once per
+// run before an encrypted store is used. If a Rekey was interrupted, either
+// the old or the new passphrase unlocks the store and the Rekey is finished
+// first.
+func Unlock(passphrase string) error {
+   kf, err := readKeyFile()
+   if err != nil {
+       return err
+   }
+   cur, err := deriveKey(passphrase, kf.keyParams)
+   if err != nil {
+       return err
+   }
+   p := kf.Pending
+   if p == nil {
+       if cur == nil {
+           return ErrPassphrase
+       }
+       dataKey = cur
+       return nil
+   }
+   var next []byte
+   switch {
+   case cur != nil && p.Key != nil:
+       if next, err = open(cur, p.NewKey); err != nil {
+           return fmt.Errorf("recovering the new key: %w", err)
+       }
+   case cur == nil && p.Key != nil:
+       if next, err = deriveKey(passphrase, *p.Key); err != nil {
+           return err
+       }
+       if next == nil {
+           return ErrPassphrase
+       }
+       if len(kf.Check) > 0 {
+           if cur, err = open(next, p.OldKey); err != nil {
+               return fmt.Errorf("recovering the old key: %w", err)
+           }
+       }
+   case cur == nil:
+       return ErrPassphrase
+   }
+   return finishRekey(kf, cur, next)
+}
+
+// deriveKey derives the key for passphrase with the parameters kp. It
+// returns nil if the passphrase does not match or kp holds no key.
+func deriveKey(passphrase string, kp keyParams) ([]byte, error) {
+   if len(kp.Check) == 0 {
+       return nil, nil
+   }
+   key, err := scrypt.Key([]byte(passphrase), kp.Salt, kp.N, kp.R, kp.P, 32)
+   if err != nil {
+       return nil, fmt.Errorf("deriving key: %w", err)
+   }
+   check, err := open(key, kp.Check)
+   if err != nil || !bytes.Equal(check, encMagic) {
+       return nil, nil
+   }
+   return key, nil
+}
+
+// readKeyFile reads and parses the key file.
+func readKeyFile() (*keyFile, error) {
+   path, err := keyFilePath()
+   if err != nil {
+       return nil, err
+   }
+   data, err := os.ReadFile(path)
+   if err != nil {
+       return nil, fmt.Errorf("reading key file: %w", err)
+   }
+   var kf keyFile
+   if err := json.Unmarshal(data, &kf); err != nil {
+       return nil, fmt.Errorf("parsing key file: %w", err)
+   }
+   return &kf, nil
+}
+
+// writeKeyFile replaces the key file with kf.
+func writeKeyFile(kf *keyFile) error {
+   path, err := keyFilePath()
+   if err != nil {
+       return err
+   }
+   data, err := json.MarshalIndent(kf, "", "  ")
+   if err != nil {
+       return fmt.Errorf("encoding key file: %w", err)
+   }
+   // a new store may have no directory yet
+   if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+       return fmt.Errorf("creating store dir: %w", err)
+   }
+   if err := writeAtomic(path, append(data, '\n')); err != nil {
+       return fmt.Errorf("writing key file: %w", err)
+   }
+   return nil
+}
+
+// Rekey re-encrypts every session, note, revision and trashed item with a
+// key derived from passphrase, or decrypts them all if passphrase is "".
+// An encrypted store must be unlocked first. Modification times are kept,
+// since revisions and the trash rely on them.
+//
+// The new key is recorded in the key file, next to the current one, before
+// any file is rewritten, and each file is replaced atomically. If Rekey is
+// interrupted, the next Unlock with either passphrase finishes it.
+func Rekey(passphrase string) error {
+   if !Unlocked() {
+       return ErrLocked
+   }
+   kf := &keyFile{}
+   if Encrypted() {
+       var err error
+       if kf, err = readKeyFile(); err != nil {
+           return err
+       }
+   }
+   pending := &pendingKey{}
+   var newKey []byte
+   if passphrase != "" {
+       kp := keyParams{Salt: make([]byte, 16), N: scryptN, R: scryptR, P: scryptP}
+       if _, err := rand.Read(kp.Salt); err != nil {
+           return fmt.Errorf("generating salt: %w", err)
+       }
+       var err error
+       newKey, err = scrypt.Key([]byte(passphrase), kp.Salt, kp.N, kp.R, kp.P, 32)
+       if err != nil {
+           return fmt.Errorf("deriving key: %w", err)
+       }
+       if kp.Check, err = seal(newKey, encMagic); err != nil {
+           return err
+       }
+       pending.Key = &kp
+       if dataKey != nil {
+           if pending.NewKey, err = seal(dataKey, newKey); err != nil {
+               return err
+           }
+           if pending.OldKey, err = seal(newKey, dataKey); err != nil {
+               return err
+           }
+       }
+   }
+   kf.Pending = pending
+   if err := writeKeyFile(kf); err != nil {
+       return err
+   }
+   return finishRekey(kf, dataKey, newKey)
+}
+
+// finishRekey rewrites every store file with newKey, reading files sealed
+// with either key, then makes newKey the current key. newKey is nil when
+// decrypting the store, and old is nil when encrypting a plaintext store.
+func finishRekey(kf *keyFile, old, newKey []byte) error {
+   dataKey, oldKey = newKey, old
+   base, err := baseDir()
+   if err != nil {
+       return err
+   }
+   for _, name := range []string{sessionsDirName, notesDirName, historyDirName, trashDirName} {
+       err := filepath.WalkDir(filepath.Join(base, name), func(path string, d fs.DirEntry, err error) error {
+           if err != nil {
+               if errors.Is(err, fs.ErrNotExist) {
+                   return nil
+               }
+               return err
+           }
+           if d.IsDir() {
+               return os.Chmod(path, dirPerm)
+           }
+           // temporary files are left only by an interrupted write
+           if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+               return nil
+           }
+           return rewriteFile(path, newKey)
+       })
+       if err != nil {
+           return fmt.Errorf("re-encrypting %s: %w", name, err)
+       }
+   }
+   if newKey == nil {
+       path, err := keyFilePath()
+       if err != nil {
+           return err
+       }
+       if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+           return fmt.Errorf("removing key file: %w", err)
+       }
+   } else {
+       if err := writeKeyFile(&keyFile{keyParams: *kf.Pending.Key}); err != nil {
+           return err
+       }
+       // an exported link graph would leave the note titles in plaintext
+       if err := os.Remove(filepath.Join(base, linkGraphFileName)); err != nil && !os.IsNotExist(err) {
+           return fmt.Errorf("removing link graph: %w", err)
+       }
+   }
+   oldKey = nil
+   return nil
+}
+
+// rewriteFile re-encrypts the file at path with key, or decrypts it if key
+// is nil, keeping its modification time.
+func rewriteFile(path string, key []byte) error {
+   info, err := os.Stat(path)
+   if err != nil {
+       return err
+   }
+   data, err := readFile(path)
+   if err != nil {
+       return err
+   }
+   if key != nil {
+       if data, err = seal(key, data); err != nil {
+           return err
+       }
+   }
+   if err := writeAtomic(path, data); err != nil {
+       return err
+   }
+   return os.Chtimes(path, info.ModTime(), info.ModTime())
+}
+
+// readFile reads a file written by writeFile, decrypting it if needed.
+// Plaintext files, such as ones from before encryption was enabled, are
+// returned as they are.
+func readFile(path string) ([]byte, error) {
+   data, err := os.ReadFile(path)
+   if err != nil {
+       return nil, err
+   }
+   if !bytes.HasPrefix(data, encMagic) {
+       return data, nil
+   }
+   if dataKey == nil && oldKey == nil {
+       return nil, ErrLocked
+   }
+   // during a Rekey a file may still be sealed with the old key
+   for _, key := range [][]byte{dataKey, oldKey} {
+       if key == nil {
+           continue
+       }
+       var plain []byte
+       if plain, err = open(key, data); err == nil {
+           return plain, nil
+       }
+   }
+   return nil, fmt.Errorf("decrypting %s: %w", filepath.Base(path), err)
+}
+
+// writeFile writes data to path, encrypted if the store is encrypted, and
+// readable only by the user.
+func writeFile(path string, data []byte) error {
+   if dataKey == nil && Encrypted() {
+       return ErrLocked
+   }
+   if dataKey != nil {
+       var err error
+       if data, err = seal(dataKey, data); err != nil {
+           return err
+       }
+   }
+   return writeAtomic(path, data)
+}
+
+// writeAtomic replaces the file at path with data via a temporary file, so
+// a crash never leaves it half written.
+func writeAtomic(path string, data []byte) error {
+   f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+   if err != nil {
+       return err
+   }
+   defer os.Remove(f.Name())
+   if _, err := f.Write(data); err != nil {
+       f.Close()
+       return err
+   }
+   if err := f.Chmod(filePerm); err != nil {
+       f.Close()
+       return err
+   }
+   // on disk before the rename makes it visible
+   if err := f.Sync(); err != nil {
+       f.Close()
+       return err
+   }
+   if err := f.Close(); err != nil {
+       return err
+   }
+   return os.Rename(f.Name(), path)
+}
+
+// seal encrypts plain with AES-256-GCM, returning encMagic, the nonce and
+// the ciphertext.
+func seal(key, plain []byte) ([]byte, error) {
+   gcm, err := newGCM(key)
+   if err != nil {
+       return nil, err
+   }
+   nonce := make([]byte, gcm.NonceSize())
+   if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+       return nil, fmt.Errorf("generating nonce: %w", err)
+   }
+   out := append(append([]byte{}, encMagic...), nonce...)
+   return gcm.Seal(out, nonce, plain, encMagic), nil
+}
+
+// open decrypts data produced by seal.
+func open(key, data []byte) ([]byte, error) {
+   gcm, err := newGCM(key)
+   if err != nil {
+       return nil, err
+   }
+   data = bytes.TrimPrefix(data, encMagic)
+   if len(data) < gcm.NonceSize() {
+       return nil, errors.New("encrypted file is truncated")
+   }
+   nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+   return gcm.Open(nil, nonce, sealed, encMagic)
+}
+
+// newGCM returns the AEAD used for store files.
+func newGCM(key []byte) (cipher.AEAD, error) {
+   block, err := aes.NewCipher(key)
+   if err != nil {
+       return nil, fmt.Errorf("creating cipher: %w", err)
+   }
+   return cipher.NewGCM(block)
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+   "bytes"
+   "crypto/rand"
+   "errors"
+   "os"
+   "path/filepath"
+   "testing"
+   "time"
+
+   "golang.org/x/crypto/scrypt"
+)
+
+// lock forgets the keys, as a new run of the program would.
+func lock() {
+   dataKey, oldKey = nil, nil
+}
+
+// tempStore points the store at an empty home directory, locked again once
+// the test ends.
+func tempStore(t *testing.T) {
+   t.Helper()
+   t.Setenv("HOME", t.TempDir())
+   lock()
+   t.Cleanup(lock)
+}
+
+// saveNote saves a note with body and returns the path of its file.
+func saveNote(t *testing.T, body string) (*Note, string) {
+   t.Helper()
+   n := NewNote("", body)
+   n.Title = body
+   path, err := n.Save()
+   if err != nil {
+       t.Fatal(err)
+   }
+   return n, path
+}
+
+// isSealed reports whether the file at path is encrypted.
+func isSealed(t *testing.T, path string) bool {
+   t.Helper()
+   data, err := os.ReadFile(path)
+   if err != nil {
+       t.Fatal(err)
+   }
+   return bytes.HasPrefix(data, encMagic)
+}
+
+// wantBody checks that note id can be loaded and holds body.
+func wantBody(t *testing.T, id, body string) {
+   t.Helper()
+   n, err := LoadNote(id)
+   if err != nil {
+       t.Fatalf("LoadNote(%s): %v", id, err)
+   }
+   if n.Body != body {
+       t.Errorf("note %s body = %q, want %q", id, n.Body, body)
+   }
+}
+
+func TestRekey(t *testing.T) {
+   tempStore(t)
+   n, path := saveNote(t, "plain")
+   old := time.Now().Add(-time.Hour).Truncate(time.Second)
+   if err := os.Chtimes(path, old, old); err != nil {
+       t.Fatal(err)
+   }
+
+   // encrypt the plaintext store
+   if err := Rekey("one"); err != nil {
+       t.Fatalf("Rekey(one): %v", err)
+   }
+   if !Encrypted() || !isSealed(t, path) {
+       t.Fatal("the store is not encrypted")
+   }
+   lock()
+   if _, err := LoadNote(n.ID); !errors.Is(err, ErrLocked) {
+       t.Fatalf("LoadNote while locked: err = %v, want ErrLocked", err)
+   }
+   if err := Unlock("two"); !errors.Is(err, ErrPassphrase) {
+       t.Fatalf("Unlock(two): err = %v, want ErrPassphrase", err)
+   }
+   if err := Unlock("one"); err != nil {
+       t.Fatalf("Unlock(one): %v", err)
+   }
+   wantBody(t, n.ID, "plain")
+
+   // change the passphrase
+   if err := Rekey("two"); err != nil {
+       t.Fatalf("Rekey(two): %v", err)
+   }
+   lock()
+   if err := Unlock("one"); !errors.Is(err, ErrPassphrase) {
+       t.Fatalf("Unlock with the old passphrase: err = %v, want ErrPassphrase", err)
+   }
+   if err := Unlock("two"); err != nil {
+       t.Fatalf("Unlock(two): %v", err)
+   }
+   wantBody(t, n.ID, "plain")
+
+   // decrypt
+   if err := Rekey(""); err != nil {
+       t.Fatalf("Rekey(\"\"): %v", err)
+   }
+   if Encrypted() || isSealed(t, path) {
+       t.Fatal("the store is still encrypted")
+   }
+   lock()
+   wantBody(t, n.ID, "plain")
+   info, err := os.Stat(path)
+   if err != nil {
+       t.Fatal(err)
+   }
+   if !info.ModTime().Equal(old) {
+       t.Errorf("modification time = %v, want %v kept", info.ModTime(), old)
+   }
+}
+
+// interruptRekey starts moving an unlocked store to a key for passphrase,
+// as Rekey does, but stops after rewriting only the file at done.
+func interruptRekey(t *testing.T, passphrase, done string) {
+   t.Helper()
+   kf, err := readKeyFile()
+   if err != nil {
+       t.Fatal(err)
+   }
+   kp := keyParams{Salt: make([]byte, 16), N: scryptN, R: scryptR, P: scryptP}
+   if _, err := rand.Read(kp.Salt); err != nil {
+       t.Fatal(err)
+   }
+   newKey, err := scrypt.Key([]byte(passphrase), kp.Salt, kp.N, kp.R, kp.P, 32)
+   if err != nil {
+       t.Fatal(err)
+   }
+   p := &pendingKey{Key: &kp}
+   if kp.Check, err = seal(newKey, encMagic); err != nil {
+       t.Fatal(err)
+   }
+   if p.NewKey, err = seal(dataKey, newKey); err != nil {
+       t.Fatal(err)
+   }
+   if p.OldKey, err = seal(newKey, dataKey); err != nil {
+       t.Fatal(err)
+   }
+   kf.Pending = p
+   if err := writeKeyFile(kf); err != nil {
+       t.Fatal(err)
+   }
+   if err := rewriteFile(done, newKey); err != nil {
+       t.Fatal(err)
+   }
+   lock()
+}
+
+func TestUnlockFinishesRekey(t *testing.T) {
+   for _, pass := range []string{"old", "new"} {
+       t.Run("unlock with "+pass, func(t *testing.T) {
+           tempStore(t)
+           if err := Rekey("old"); err != nil {
+               t.Fatal(err)
+           }
+           a, pathA := saveNote(t, "rewritten")
+           b, _ := saveNote(t, "not rewritten")
+           interruptRekey(t, "new", pathA)
+           if !RekeyPending() {
+               t.Fatal("no rekey is pending")
+           }
+
+           if err := Unlock("other"); !errors.Is(err, ErrPassphrase) {
+               t.Fatalf("Unlock(other): err = %v, want ErrPassphrase", err)
+           }
+           if err := Unlock(pass); err != nil {
+               t.Fatalf("Unlock(%s): %v", pass, err)
+           }
+           if RekeyPending() {
+               t.Error("the rekey is still pending")
+           }
+           wantBody(t, a.ID, "rewritten")
+           wantBody(t, b.ID, "not rewritten")
+
+           // every file is sealed with the new key only
+           lock()
+           if err := Unlock("old"); !errors.Is(err, ErrPassphrase) {
+               t.Fatalf("Unlock(old) after the rekey: err = %v, want ErrPassphrase", err)
+           }
+           if err := Unlock("new"); err != nil {
+               t.Fatalf("Unlock(new): %v", err)
+           }
+           wantBody(t, a.ID, "rewritten")
+           wantBody(t, b.ID, "not rewritten")
+       })
+   }
+}
+
+func TestWriteLinkGraphEncrypted(t *testing.T) {
+   tempStore(t)
+   saveNote(t, "see [[other]]")
+   if _, err := WriteLinkGraph(nil); err != nil {
+       t.Fatal(err)
+   }
+   if err := Rekey("one"); err != nil {
+       t.Fatal(err)
+   }
+   if _, err := WriteLinkGraph(nil); !errors.Is(err, ErrGraphEncrypted) {
+       t.Fatalf("err = %v, want ErrGraphEncrypted", err)
+   }
+   base, err := baseDir()
+   if err != nil {
+       t.Fatal(err)
+   }
+   if _, err := os.Stat(filepath.Join(base, linkGraphFileName)); !os.IsNotExist(err) {
+       t.Errorf("the plaintext link graph is kept: %v", err)
+   }
+}
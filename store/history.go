@@ -0,0 +1,118 @@
+package store
+
+import (
+   "bytes"
+   "fmt"
+   "os"
+   "path/filepath"
+   "sort"
+   "strings"
+   "time"
+)
+
+const (
+   historyDirName = "history"
+
+   // revisionStamp names revision files after the time they were saved
+   revisionStamp = "20060102T150405.000000000"
+)
+
+// Revision is an earlier version of a note, kept in
+// ~/.ai-notes/history/<note ID> whenever the note is saved over.
+type Revision struct {
+   NoteID  string
+   SavedAt time.Time
+   path    string
+}
+
+// historyDir returns the directory holding the revisions of a note.
+func historyDir(noteID string) (string, error) {
+   base, err := baseDir()
+   if err != nil {
+       return "", err
+   }
+   return filepath.Join(base, historyDirName, noteID), nil
+}
+
+// keepRevision copies the note file at path into the note's history unless
+// it already holds content. The revision is named after the file's
+// modification time, i.e. when that version was saved.
+func keepRevision(noteID, path string, content []byte) error {
+   old, err := readFile(path)
+   if err != nil {
+       if os.IsNotExist(err) {
+           return nil
+       }
+       return fmt.Errorf("reading note file: %w", err)
+   }
+   if bytes.Equal(old, content) {
+       return nil
+   }
+   info, err := os.Stat(path)
+   if err != nil {
+       return fmt.Errorf("reading note file: %w", err)
+   }
+   dir, err := historyDir(noteID)
+   if err != nil {
+       return err
+   }
+   if err := os.MkdirAll(dir, dirPerm); err != nil {
+       return fmt.Errorf("creating history dir: %w", err)
+   }
+   name := info.ModTime().Format(revisionStamp) + ".md"
+   if err := writeFile(filepath.Join(dir, name), old); err != nil {
+       return fmt.Errorf("writing note revision: %w", err)
+   }
+   return nil
+}
+
+// Revisions lists the earlier versions of the note, newest first.
+func (n *Note) Revisions() ([]*Revision, error) {
+   dir, err := historyDir(n.ID)
+   if err != nil {
+       return nil, err
+   }
+   files, err := os.ReadDir(dir)
+   if err != nil {
+       if os.IsNotExist(err) {
+           return nil, nil
+       }
+       return nil, fmt.Errorf("reading history dir: %w", err)
+   }
+   var revs []*Revision
+   for _, fi := range files {
+       if fi.IsDir() || filepath.Ext(fi.Name()) != ".md" {
+           continue
+       }
+       t, err := time.ParseInLocation(revisionStamp, strings.TrimSuffix(fi.Name(), ".md"), time.Local)
+       if err != nil {
+           continue
+       }
+       revs = append(revs, &Revision{NoteID: n.ID, SavedAt: t, path: filepath.Join(dir, fi.Name())})
+   }
+   sort.Slice(revs, func(i, j int) bool {
+       return revs[i].SavedAt.After(revs[j].SavedAt)
+   })
+   return revs, nil
+}
+
+// Load reads the note as it was in this revision.
+func (r *Revision) Load() (*Note, error) {
+   return readNote(r.path, r.NoteID)
+}
+
+// Restore replaces the note's content with that of rev and saves it. The
+// content being replaced is kept as a revision like any other save.
+func (n *Note) Restore(rev *Revision) error {
+   old, err := rev.Load()
+   if err != nil {
+       return err
+   }
+   created := n.CreatedAt
+   *n = *old
+   if n.CreatedAt.IsZero() {
+       n.CreatedAt = created
+   }
+   _, err = n.Save()
+   return err
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+   "bytes"
+   "fmt"
+   "image"
+   "image/draw"
+   "image/jpeg"
+   "image/png"
+)
+
+const (
+   // MaxImageBytes is the largest image file that can be attached.
+   MaxImageBytes = 20 << 20
+   // MaxImageSide is the longest side an attached image is kept at; larger
+   // images are scaled down, since the provider would do so anyway.
+   MaxImageSide = 2048
+
+   // maxImagePixels rejects images that would take too much memory to decode.
+   maxImagePixels = 50_000_000
+   // reencodeBytes is the file size above which an image is re-encoded even
+   // if it is small enough.
+   reencodeBytes = 4 << 20
+)
+
+// fitImage scales down an image larger than MaxImageSide, or re-encodes one
+// whose file is unusually big, and returns the data to keep with its config
+// and format. Other images are returned unchanged.
+func fitImage(data []byte, cfg image.Config, format string) ([]byte, image.Config, string, error) {
+   if cfg.Width*cfg.Height > maxImagePixels {
+       return nil, cfg, "", fmt.Errorf("image is too large (%dx%d)", cfg.Width, cfg.Height)
+   }
+   if cfg.Width <= MaxImageSide && cfg.Height <= MaxImageSide && len(data) <= reencodeBytes {
+       return data, cfg, format, nil
+   }
+   img, _, err := image.Decode(bytes.NewReader(data))
+   if err != nil {
+       return nil, cfg, "", err
+   }
+   w, h := cfg.Width, cfg.Height
+   if w > MaxImageSide || h > MaxImageSide {
+       if w >= h {
+           w, h = MaxImageSide, max(1, h*MaxImageSide/w)
+       } else {
+           w, h = max(1, w*MaxImageSide/h), MaxImageSide
+       }
+       img = scale(img, w, h)
+   }
+   var buf bytes.Buffer
+   if format == "jpeg" {
+       err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+   } else {
+       // gif frames after the first are dropped; the model sees one anyway
+       format = "png"
+       err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+   }
+   if err != nil {
+       return nil, cfg, "", fmt.Errorf("encoding image: %w", err)
+   }
+   return buf.Bytes(), image.Config{ColorModel: img.ColorModel(), Width: w, Height: h}, format, nil
+}
+
+// scale resizes img to w x h by averaging the source pixels each target
+// pixel covers.
+func scale(img image.Image, w, h int) image.Image {
+   b := img.Bounds()
+   src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+   draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+   dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+   sw, sh := b.Dx(), b.Dy()
+   for y := 0; y < h; y++ {
+       y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+       for x := 0; x < w; x++ {
+           x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+           var sum [4]int
+           for sy := y0; sy < y1; sy++ {
+               row := src.Pix[sy*src.Stride:]
+               for sx := x0; sx < x1; sx++ {
+                   for c := 0; c < 4; c++ {
+                       sum[c] += int(row[sx*4+c])
+                   }
+               }
+           }
+           n := (y1 - y0) * (x1 - x0)
+           i := y*dst.Stride + x*4
+           for c := 0; c < 4; c++ {
+               dst.Pix[i+c] = uint8(sum[c] / n)
+           }
+       }
+   }
+   return dst
+}
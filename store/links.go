@@ -0,0 +1,191 @@
+package store
+
+import (
+   "errors"
+   "fmt"
+   "os"
+   "path/filepath"
+   "regexp"
+   "strings"
+)
+
+// linkPattern matches [[Note Title]] wiki-links.
+var linkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// LinkTargets returns the targets of the wiki-links in text, in order of
+// first appearance.
+func LinkTargets(text string) []string {
+   var targets []string
+   seen := make(map[string]bool)
+   for _, m := range linkPattern.FindAllStringSubmatch(text, -1) {
+       target := strings.TrimSpace(m[1])
+       if key := strings.ToLower(target); !seen[key] {
+           seen[key] = true
+           targets = append(targets, target)
+       }
+   }
+   return targets
+}
+
+// ReplaceLinks replaces every wiki-link in text with f applied to its target.
+func ReplaceLinks(text string, f func(target string) string) string {
+   return linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+       return f(strings.TrimSpace(link[2 : len(link)-2]))
+   })
+}
+
+// Links returns the targets of the wiki-links in the note.
+func (n *Note) Links() []string {
+   return LinkTargets(n.Markdown())
+}
+
+// ResolveLink returns the note a link target refers to: the note with that
+// title, compared case-insensitively, or else with that ID. It returns nil
+// for dangling links.
+func ResolveLink(notes []*Note, target string) *Note {
+   target = strings.TrimSpace(target)
+   for _, n := range notes {
+       if strings.EqualFold(n.Title, target) {
+           return n
+       }
+   }
+   for _, n := range notes {
+       if n.ID == target {
+           return n
+       }
+   }
+   return nil
+}
+
+// Backlinks returns the notes linking to n.
+func Backlinks(notes []*Note, n *Note) []*Note {
+   var out []*Note
+   for _, o := range notes {
+       if o == n {
+           continue
+       }
+       for _, target := range o.Links() {
+           if ResolveLink(notes, target) == n {
+               out = append(out, o)
+               break
+           }
+       }
+   }
+   return out
+}
+
+// RewriteLinks replaces wiki-links to oldTitle with links to newTitle in
+// every note and saves the notes that changed, which it returns. Read-only
+// vault notes are left alone.
+func RewriteLinks(notes []*Note, oldTitle, newTitle string) ([]*Note, error) {
+   rewrite := func(s string) string {
+       return ReplaceLinks(s, func(target string) string {
+           if strings.EqualFold(target, oldTitle) {
+               target = newTitle
+           }
+           return "[[" + target + "]]"
+       })
+   }
+   var changed []*Note
+   for _, n := range notes {
+       if n.External != "" {
+           continue
+       }
+       body := rewrite(n.Body)
+       items := make([]ActionItem, len(n.ActionItems))
+       itemsChanged := false
+       for i, it := range n.ActionItems {
+           items[i] = it
+           items[i].Text = rewrite(it.Text)
+           itemsChanged = itemsChanged || items[i].Text != it.Text
+       }
+       decisions, decisionsChanged := rewriteAll(n.Decisions, rewrite)
+       questions, questionsChanged := rewriteAll(n.OpenQuestions, rewrite)
+       if body == n.Body && !itemsChanged && !decisionsChanged && !questionsChanged {
+           continue
+       }
+       n.Body = body
+       if itemsChanged {
+           n.ActionItems = items
+       }
+       n.Decisions, n.OpenQuestions = decisions, questions
+       if _, err := n.Save(); err != nil {
+           return changed, err
+       }
+       changed = append(changed, n)
+   }
+   return changed, nil
+}
+
+// rewriteAll applies f to every string in list and reports whether any changed.
+func rewriteAll(list []string, f func(string) string) ([]string, bool) {
+   changed := false
+   out := make([]string, len(list))
+   for i, s := range list {
+       out[i] = f(s)
+       changed = changed || out[i] != s
+   }
+   if !changed {
+       return list, false
+   }
+   return out, true
+}
+
+// LinkGraphDOT renders the wiki-links between notes as a Graphviz DOT digraph.
+// Dangling links point to dashed nodes named after their target.
+func LinkGraphDOT(notes []*Note) string {
+   var b strings.Builder
+   b.WriteString("digraph notes {\n")
+   b.WriteString("  node [shape=box];\n")
+   for _, n := range notes {
+       fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(n.ID), dotQuote(n.Title))
+   }
+   dangling := make(map[string]bool)
+   for _, n := range notes {
+       for _, target := range n.Links() {
+           if to := ResolveLink(notes, target); to != nil {
+               fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(n.ID), dotQuote(to.ID))
+               continue
+           }
+           if !dangling[target] {
+               dangling[target] = true
+               fmt.Fprintf(&b, "  %s [label=%s, style=dashed];\n", dotQuote("missing:"+target), dotQuote(target))
+           }
+           fmt.Fprintf(&b, "  %s -> %s [style=dashed];\n", dotQuote(n.ID), dotQuote("missing:"+target))
+       }
+   }
+   b.WriteString("}\n")
+   return b.String()
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+   return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
+
+// linkGraphFileName is the file WriteLinkGraph writes in ~/.ai-notes.
+const linkGraphFileName = "links.dot"
+
+// ErrGraphEncrypted is returned by WriteLinkGraph for an encrypted store.
+var ErrGraphEncrypted = errors.New(`store is encrypted: print the link graph with "ai-notes graph" instead`)
+
+// WriteLinkGraph writes LinkGraphDOT(notes) to ~/.ai-notes/links.dot and
+// returns its path. It refuses to for an encrypted store, since the graph
+// holds note titles and Graphviz could not read it encrypted.
+func WriteLinkGraph(notes []*Note) (string, error) {
+   if Encrypted() {
+       return "", ErrGraphEncrypted
+   }
+   base, err := baseDir()
+   if err != nil {
+       return "", err
+   }
+   if err := os.MkdirAll(base, dirPerm); err != nil {
+       return "", fmt.Errorf("creating base dir: %w", err)
+   }
+   path := filepath.Join(base, linkGraphFileName)
+   if err := os.WriteFile(path, []byte(LinkGraphDOT(notes)), filePerm); err != nil {
+       return "", fmt.Errorf("writing link graph: %w", err)
+   }
+   return path, nil
+}
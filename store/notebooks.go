@@ -0,0 +1,92 @@
+package store
+
+import (
+   "sort"
+   "strings"
+)
+
+// NormalizeNotebook cleans a notebook path: surrounding spaces and empty
+// segments are dropped, so " work//ideas/ " becomes "work/ideas".
+func NormalizeNotebook(path string) string {
+   var parts []string
+   for _, p := range strings.Split(path, "/") {
+       if p = strings.TrimSpace(p); p != "" {
+           parts = append(parts, p)
+       }
+   }
+   return strings.Join(parts, "/")
+}
+
+// InNotebook reports whether the note is filed in notebook or one of its
+// sub-notebooks. Every note is in the root notebook "".
+func (n *Note) InNotebook(notebook string) bool {
+   return notebook == "" || n.Notebook == notebook || strings.HasPrefix(n.Notebook, notebook+"/")
+}
+
+// FilterNotebook returns the notes in notebook or its sub-notebooks.
+func FilterNotebook(notes []*Note, notebook string) []*Note {
+   if notebook == "" {
+       return notes
+   }
+   var out []*Note
+   for _, n := range notes {
+       if n.InNotebook(notebook) {
+           out = append(out, n)
+       }
+   }
+   return out
+}
+
+// Notebooks lists every notebook holding notes, including the parents of
+// nested notebooks, sorted by path.
+func Notebooks(notes []*Note) []string {
+   seen := make(map[string]bool)
+   for _, n := range notes {
+       path := n.Notebook
+       for path != "" && !seen[path] {
+           seen[path] = true
+           i := strings.LastIndex(path, "/")
+           if i < 0 {
+               break
+           }
+           path = path[:i]
+       }
+   }
+   out := make([]string, 0, len(seen))
+   for nb := range seen {
+       out = append(out, nb)
+   }
+   sort.Strings(out)
+   return out
+}
+
+// ParentNotebook returns the notebook containing notebook, "" at the top level.
+func ParentNotebook(notebook string) string {
+   if i := strings.LastIndex(notebook, "/"); i >= 0 {
+       return notebook[:i]
+   }
+   return ""
+}
+
+// MoveNotebook files the notes of notebook and its sub-notebooks under
+// newPath, keeping their relative place, and saves them. It returns the
+// moved notes; read-only vault notes stay where they are.
+func MoveNotebook(notes []*Note, notebook, newPath string) ([]*Note, error) {
+   var moved []*Note
+   for _, n := range FilterNotebook(notes, notebook) {
+       if n.External != "" {
+           continue
+       }
+       old := n.Notebook
+       n.Notebook = NormalizeNotebook(newPath + strings.TrimPrefix(old, notebook))
+       if n.Notebook == old {
+           continue
+       }
+       if _, err := n.Save(); err != nil {
+           n.Notebook = old
+           return moved, err
+       }
+       moved = append(moved, n)
+   }
+   return moved, nil
+}
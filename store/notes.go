@@ -1,10 +1,12 @@
 package store
 
 import (
+   "encoding/json"
    "fmt"
    "os"
    "path/filepath"
    "sort"
+   "strconv"
    "strings"
    "time"
 )
@@ -20,15 +22,42 @@ type Note struct {
    Title     string    // human-readable title for the note
    Body      string    // content of the note (summary)
    CreatedAt time.Time // when the note was created
+   Template  string    // name of the note template used to generate the note
+   // source message range in the session's chat: [MessageStart, MessageEnd)
+   MessageStart int
+   MessageEnd   int
+
+   // structured fields, filled when the note was extracted as JSON
+   Tags          []string
+   ActionItems   []ActionItem
+   Decisions     []string
+   OpenQuestions []string
+
+   // MergedFrom lists the IDs of the notes this note was merged from
+   MergedFrom []string
+
+   // Notebook is the slash-separated notebook path, "" for no notebook
+   Notebook string
+
+   // External is the vault file an indexed note was read from; such notes
+   // are read-only. It is "" for notes in ~/.ai-notes/notes.
+   External string
+}
+
+// ActionItem is a task extracted from a session.
+type ActionItem struct {
+   Text  string `json:"text"`
+   Owner string `json:"owner,omitempty"`
+   Due   string `json:"due,omitempty"` // YYYY-MM-DD
+   Done  bool   `json:"done,omitempty"`
 }
 
 // notesDir returns the full path to notes directory (~/.ai-notes/notes).
 func notesDir() (string, error) {
-   home, err := os.UserHomeDir()
+   base, err := baseDir()
    if err != nil {
-       return "", fmt.Errorf("could not determine home directory: %w", err)
+       return "", err
    }
-   base := filepath.Join(home, baseDirName)
    return filepath.Join(base, notesDirName), nil
 }
 
@@ -50,44 +79,9 @@ func LoadNotes() ([]*Note, error) {
        if fi.IsDir() || filepath.Ext(fi.Name()) != ".md" {
            continue
        }
-       path := filepath.Join(dir, fi.Name())
-       data, err := os.ReadFile(path)
+       note, err := readNote(filepath.Join(dir, fi.Name()), strings.TrimSuffix(fi.Name(), ".md"))
        if err != nil {
-           return nil, fmt.Errorf("reading note file %s: %w", fi.Name(), err)
-       }
-       text := string(data)
-       // split title and body
-       parts := strings.SplitN(text, "\n", 2)
-       title := strings.TrimPrefix(parts[0], "# ")
-       body := ""
-       if len(parts) > 1 {
-           body = strings.TrimSpace(parts[1])
-       }
-       // parse ID and SessionID from title: Notes-{sessionID}-{id}
-       titleParts := strings.SplitN(title, "-", 3)
-       var sessionID, id string
-       var createdAt time.Time
-       if len(titleParts) == 3 && titleParts[0] == "Notes" {
-           sessionID = titleParts[1]
-           id = titleParts[2]
-           t, err := time.Parse("20060102T150405", id)
-           if err == nil {
-               createdAt = t
-           }
-       } else {
-           // fallback: use filename (without ext) as ID
-           id = strings.TrimSuffix(fi.Name(), ".md")
-           t, err := time.Parse("20060102T150405", id)
-           if err == nil {
-               createdAt = t
-           }
-       }
-       note := &Note{
-           ID:        id,
-           SessionID: sessionID,
-           Title:     title,
-           Body:      body,
-           CreatedAt: createdAt,
+           return nil, err
        }
        notes = append(notes, note)
    }
@@ -98,10 +92,62 @@ func LoadNotes() ([]*Note, error) {
    return notes, nil
 }
 
+// LoadNote reads a single note by ID from ~/.ai-notes/notes.
+func LoadNote(id string) (*Note, error) {
+   dir, err := notesDir()
+   if err != nil {
+       return nil, err
+   }
+   return readNote(filepath.Join(dir, id+".md"), id)
+}
+
+// readNote parses the markdown note file at path as the note with the given
+// ID. The ID comes from the file name, never from the title, which is only
+// displayed and may be renamed to anything.
+func readNote(path, id string) (*Note, error) {
+   data, err := readFile(path)
+   if err != nil {
+       return nil, fmt.Errorf("reading note file %s: %w", filepath.Base(path), err)
+   }
+   meta, text := parseFrontmatter(string(data))
+   // split title and body
+   parts := strings.SplitN(text, "\n", 2)
+   title := strings.TrimPrefix(parts[0], "# ")
+   body := ""
+   if len(parts) > 1 {
+       body = strings.TrimSpace(parts[1])
+   }
+   var createdAt time.Time
+   // IDs are timestamps, with a "-N" suffix if several share a second
+   stamp, _, _ := strings.Cut(id, "-")
+   if t, err := time.Parse("20060102T150405", stamp); err == nil {
+       createdAt = t
+   }
+   // notes saved without frontmatter name their session only in the
+   // default title: Notes-{sessionID}-{id}
+   var sessionID string
+   if rest, ok := strings.CutPrefix(title, "Notes-"); ok {
+       if sid, ok := strings.CutSuffix(rest, "-"+id); ok {
+           sessionID = sid
+       }
+   }
+   note := &Note{
+       ID:        id,
+       SessionID: sessionID,
+       Title:     title,
+       Body:      body,
+       CreatedAt: createdAt,
+   }
+   note.applyMeta(meta)
+   return note, nil
+}
+
 // NewNote creates a new Note for a given session ID with the provided body.
+// The ID is unique among saved notes even when several notes are created
+// within the same second.
 func NewNote(sessionID, body string) *Note {
    now := time.Now()
-   id := now.Format("20060102T150405")
+   id := uniqueNoteID(now)
    title := fmt.Sprintf("Notes-%s-%s", sessionID, id)
    return &Note{
        ID:        id,
@@ -113,28 +159,206 @@ func NewNote(sessionID, body string) *Note {
 }
 
 // Save writes the note as a markdown file to ~/.ai-notes/notes/{ID}.md.
+// The file is encrypted if the store is encrypted. The version it replaces,
+// if different, is kept in the note's history.
 // Returns the full file path or an error.
 func (n *Note) Save() (string, error) {
-   home, err := os.UserHomeDir()
+   if n.External != "" {
+       return "", ErrReadOnly
+   }
+   dir, err := notesDir()
    if err != nil {
-       return "", fmt.Errorf("could not determine home directory: %w", err)
+       return "", err
    }
-   base := filepath.Join(home, baseDirName)
-   dir := filepath.Join(base, notesDirName)
-   if err := os.MkdirAll(dir, 0o755); err != nil {
+   if err := os.MkdirAll(dir, dirPerm); err != nil {
        return "", fmt.Errorf("creating notes dir: %w", err)
    }
    filename := n.ID + ".md"
    path := filepath.Join(dir, filename)
-   f, err := os.Create(path)
-   if err != nil {
-       return "", fmt.Errorf("creating note file: %w", err)
+   // markdown: metadata, title and body
+   content := []byte(fmt.Sprintf("%s# %s\n\n%s", n.frontmatter(), n.Title, n.Body))
+   // keep the version being overwritten
+   if err := keepRevision(n.ID, path, content); err != nil {
+       return "", err
    }
-   defer f.Close()
-   // write markdown: title and body
-   _, err = fmt.Fprintf(f, "# %s\n\n%s", n.Title, n.Body)
-   if err != nil {
+   if err := writeFile(path, content); err != nil {
        return "", fmt.Errorf("writing note file: %w", err)
    }
    return path, nil
-}
\ No newline at end of file
+}
+
+// parseFrontmatter splits a leading "---" delimited block of "key: value" lines
+// from text. It returns the parsed keys and the remaining text.
+func parseFrontmatter(text string) (map[string]string, string) {
+   meta := make(map[string]string)
+   if !strings.HasPrefix(text, "---\n") {
+       return meta, text
+   }
+   rest := text[len("---\n"):]
+   end := strings.Index(rest, "\n---\n")
+   if end < 0 {
+       return meta, text
+   }
+   for _, line := range strings.Split(rest[:end], "\n") {
+       key, value, ok := strings.Cut(line, ":")
+       if !ok {
+           continue
+       }
+       meta[strings.TrimSpace(key)] = strings.TrimSpace(value)
+   }
+   return meta, strings.TrimLeft(rest[end+len("\n---\n"):], "\n")
+}
+
+// applyMeta copies known frontmatter keys onto the note.
+func (n *Note) applyMeta(meta map[string]string) {
+   for key, value := range meta {
+       switch key {
+       case "session":
+           n.SessionID = value
+       case "created":
+           if t, err := time.Parse(time.RFC3339, value); err == nil {
+               n.CreatedAt = t
+           }
+       case "merged_from":
+           _ = json.Unmarshal([]byte(value), &n.MergedFrom)
+       case "template":
+           n.Template = value
+       case "notebook":
+           n.Notebook = value
+       case "tags":
+           _ = json.Unmarshal([]byte(value), &n.Tags)
+       case "action_items":
+           _ = json.Unmarshal([]byte(value), &n.ActionItems)
+       case "decisions":
+           _ = json.Unmarshal([]byte(value), &n.Decisions)
+       case "open_questions":
+           _ = json.Unmarshal([]byte(value), &n.OpenQuestions)
+       case "messages":
+           // stored as "start-end", end exclusive
+           start, end, _ := strings.Cut(value, "-")
+           n.MessageStart, _ = strconv.Atoi(start)
+           n.MessageEnd, _ = strconv.Atoi(end)
+       }
+   }
+}
+
+// frontmatter renders the note's metadata block, or "" if there is none.
+// List values are written as single-line JSON, which is also valid YAML.
+func (n *Note) frontmatter() string {
+   var b strings.Builder
+   field := func(key, value string) {
+       if value != "" {
+           fmt.Fprintf(&b, "%s: %s\n", key, value)
+       }
+   }
+   list := func(key string, length int, v any) {
+       if length > 0 {
+           data, _ := json.Marshal(v)
+           field(key, string(data))
+       }
+   }
+   field("session", n.SessionID)
+   if !n.CreatedAt.IsZero() {
+       field("created", n.CreatedAt.Format(time.RFC3339))
+   }
+   field("template", n.Template)
+   field("notebook", n.Notebook)
+   if n.MessageEnd > 0 {
+       field("messages", fmt.Sprintf("%d-%d", n.MessageStart, n.MessageEnd))
+   }
+   list("tags", len(n.Tags), n.Tags)
+   list("action_items", len(n.ActionItems), n.ActionItems)
+   list("decisions", len(n.Decisions), n.Decisions)
+   list("open_questions", len(n.OpenQuestions), n.OpenQuestions)
+   list("merged_from", len(n.MergedFrom), n.MergedFrom)
+   if b.Len() == 0 {
+       return ""
+   }
+   return "---\n" + b.String() + "---\n"
+}
+
+// Markdown returns the note body followed by its structured fields rendered as sections.
+func (n *Note) Markdown() string {
+   var b strings.Builder
+   b.WriteString(n.Body)
+   if len(n.ActionItems) > 0 {
+       b.WriteString("\n\n## Action items\n")
+       for _, it := range n.ActionItems {
+           b.WriteString("\n" + it.String())
+       }
+   }
+   section := func(title string, items []string) {
+       if len(items) == 0 {
+           return
+       }
+       b.WriteString("\n\n## " + title + "\n")
+       for _, it := range items {
+           b.WriteString("\n- " + it)
+       }
+   }
+   section("Decisions", n.Decisions)
+   section("Open questions", n.OpenQuestions)
+   return b.String()
+}
+
+// String renders the action item as a markdown task list entry.
+func (a ActionItem) String() string {
+   check := " "
+   if a.Done {
+       check = "x"
+   }
+   s := fmt.Sprintf("- [%s] %s", check, a.Text)
+   var extra []string
+   if a.Owner != "" {
+       extra = append(extra, "@"+a.Owner)
+   }
+   if a.Due != "" {
+       extra = append(extra, "due "+a.Due)
+   }
+   if len(extra) > 0 {
+       s += " (" + strings.Join(extra, ", ") + ")"
+   }
+   return s
+}
+
+// SourceRange describes the note's source messages for display, 1-based and inclusive.
+func (n *Note) SourceRange() string {
+   if n.MessageEnd <= n.MessageStart {
+       return ""
+   }
+   return fmt.Sprintf("messages %d-%d", n.MessageStart+1, n.MessageEnd)
+}
+
+// uniqueNoteID returns a timestamp ID for t, adding a numeric suffix if a note
+// with that ID already exists.
+func uniqueNoteID(t time.Time) string {
+   id := t.Format("20060102T150405")
+   dir, err := notesDir()
+   if err != nil {
+       return id
+   }
+   candidate := id
+   for i := 2; ; i++ {
+       if _, err := os.Stat(filepath.Join(dir, candidate+".md")); err != nil {
+           return candidate
+       }
+       candidate = fmt.Sprintf("%s-%d", id, i)
+   }
+}
+
+// Duplicate returns an unsaved copy of the note with a new ID and creation
+// time. Copies of vault notes are ordinary, writable notes.
+func (n *Note) Duplicate() *Note {
+   c := *n
+   now := time.Now()
+   c.ID = uniqueNoteID(now)
+   c.CreatedAt = now
+   c.Title = n.Title + " (copy)"
+   c.External = ""
+   c.Tags = append([]string(nil), n.Tags...)
+   c.ActionItems = append([]ActionItem(nil), n.ActionItems...)
+   c.Decisions = append([]string(nil), n.Decisions...)
+   c.OpenQuestions = append([]string(nil), n.OpenQuestions...)
+   c.MergedFrom = append([]string(nil), n.MergedFrom...)
+   return &c
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+   "strings"
+)
+
+// matchWords reports whether text contains every word of query, ignoring case.
+func matchWords(text, query string) bool {
+   text = strings.ToLower(text)
+   for _, word := range strings.Fields(strings.ToLower(query)) {
+       if !strings.Contains(text, word) {
+           return false
+       }
+   }
+   return true
+}
+
+// Matches reports whether the note's title, content or tags contain every
+// word of query. An empty query matches every note.
+func (n *Note) Matches(query string) bool {
+   return matchWords(n.Title+" "+n.Markdown()+" "+strings.Join(n.Tags, " "), query)
+}
+
+// Matches reports whether the session's title, description or messages
+// contain every word of query. An empty query matches every session.
+func (s *Session) Matches(query string) bool {
+   var b strings.Builder
+   b.WriteString(s.DisplayTitle() + " " + s.Description)
+   for _, msg := range s.Chat {
+       b.WriteString(" " + msg.Content)
+   }
+   return matchWords(b.String(), query)
+}
@@ -1,8 +1,15 @@
 package store
 
 import (
+   "bytes"
+   "crypto/sha256"
+   "encoding/hex"
    "encoding/json"
    "fmt"
+   "image"
+   _ "image/gif"
+   _ "image/jpeg"
+   _ "image/png"
    "os"
    "path/filepath"
    "sort"
@@ -14,23 +21,108 @@ const (
    sessionsDirName = "sessions"
 )
 
-// Message represents a single chat message with role (user or assistant) and content.
+// Message represents a single chat message with role (user, assistant or
+// tool) and content. Parts holds structured attachments that were sent along
+// with the content.
 type Message struct {
    Role    string `json:"role"`
    Content string `json:"content"`
+   Parts   []Part `json:"parts,omitempty"`
+   // Time is when the message was written, if known (set for imported messages)
+   Time *time.Time `json:"time,omitempty"`
+   // Redactions logs what was replaced in the message the last time it was
+   // sent to the provider. The redacted values themselves are not kept.
+   Redactions []Redaction `json:"redactions,omitempty"`
+   // ToolCalls are the tools an assistant message asked to run. Their
+   // results follow in messages with role "tool".
+   ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+   // ToolCallID and Name identify the call a "tool" message is the result of.
+   ToolCallID string `json:"tool_call_id,omitempty"`
+   Name       string `json:"name,omitempty"`
+}
+
+// Roles of messages besides "user", "assistant" and "system".
+const (
+   // RoleTool is the role of a message holding the result of a tool call.
+   RoleTool = "tool"
+   // RoleStatus is the role of status and error lines written by the app,
+   // which are shown in the chat but not sent to the provider.
+   RoleStatus = "status"
+)
+
+// ToolCall is a tool the assistant asked to run. Arguments is a JSON object.
+type ToolCall struct {
+   ID        string `json:"id"`
+   Name      string `json:"name"`
+   Arguments string `json:"arguments"`
+}
+
+// Redaction is one value replaced by a placeholder before sending a message.
+type Redaction struct {
+   Rule        string `json:"rule"`
+   Placeholder string `json:"placeholder"`
+}
+
+// Part types stored in Message.Parts.
+const (
+   PartFile  = "file"
+   PartImage = "image"
+)
+
+// Part is a structured piece of context attached to a message, such as a file
+// or one chunk of a large file. Hash is the hex sha256 of the whole file so the
+// session records exactly which version of the file was sent.
+type Part struct {
+   Type     string `json:"type"`
+   Filename string `json:"filename"`
+   Hash     string `json:"hash"`
+   Chunk    int    `json:"chunk,omitempty"`  // 1-based chunk index, 0 if the file was not split
+   Chunks   int    `json:"chunks,omitempty"` // total number of chunks for the file
+   Text     string `json:"text,omitempty"`
+   // image parts only; the image bytes live in the session directory under Hash
+   MimeType string `json:"mime_type,omitempty"`
+   Width    int    `json:"width,omitempty"`
+   Height   int    `json:"height,omitempty"`
 }
 
 // Session holds the metadata and chat history for a conversation.
 type Session struct {
-   ID        string    `json:"id"`
-   CreatedAt time.Time `json:"created_at"`
-   Chat      []Message `json:"chat"`
+   ID          string    `json:"id"`
+   Title       string    `json:"title,omitempty"`
+   Description string    `json:"description,omitempty"`
+   CreatedAt   time.Time `json:"created_at"`
+   UpdatedAt   time.Time `json:"updated_at,omitempty"` // time of the last chat message
+   Chat        []Message `json:"chat"`
+   Usage       Usage     `json:"usage"`
+
+   // NotedUpTo is the number of leading Chat messages covered by the latest note.
+   NotedUpTo int `json:"noted_up_to,omitempty"`
+   // LivingNoteID is the note that is updated in place as the session grows.
+   LivingNoteID string `json:"living_note_id,omitempty"`
+   // Notebook is the notebook new notes of the session are filed in.
+   Notebook string `json:"notebook,omitempty"`
+}
+
+// Usage accumulates the tokens and cost of the API requests made for a session.
+type Usage struct {
+   PromptTokens     int     `json:"prompt_tokens,omitempty"`
+   CompletionTokens int     `json:"completion_tokens,omitempty"`
+   Cost             float64 `json:"cost,omitempty"` // USD
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+   u.PromptTokens += o.PromptTokens
+   u.CompletionTokens += o.CompletionTokens
+   u.Cost += o.Cost
 }
 
 // NewSession creates a new session with a time-based ID and current timestamp.
+// The ID is unique among saved sessions even when several sessions are
+// created within the same second.
 func NewSession() *Session {
    now := time.Now()
-   id := now.Format("20060102T150405")
+   id := uniqueSessionID(now)
    return &Session{
        ID:        id,
        CreatedAt: now,
@@ -38,27 +130,41 @@ func NewSession() *Session {
    }
 }
 
-// Save writes the session as JSON to ~/.ai-notes/sessions/{ID}.json.
+// uniqueSessionID returns a timestamp ID for t, adding a numeric suffix if a
+// session with that ID already exists.
+func uniqueSessionID(t time.Time) string {
+   id := t.Format("20060102T150405")
+   dir, err := sessionsDir()
+   if err != nil {
+       return id
+   }
+   candidate := id
+   for i := 2; ; i++ {
+       if _, err := os.Stat(filepath.Join(dir, candidate+".json")); err != nil {
+           return candidate
+       }
+       candidate = fmt.Sprintf("%s-%d", id, i)
+   }
+}
+
+// Save writes the session as JSON to ~/.ai-notes/sessions/{ID}.json,
+// encrypted if the store is encrypted.
 func (s *Session) Save() error {
    dir, err := sessionsDir()
    if err != nil {
        return err
    }
    // ensure directory exists
-   if err := os.MkdirAll(dir, 0o755); err != nil {
+   if err := os.MkdirAll(dir, dirPerm); err != nil {
        return fmt.Errorf("creating sessions dir: %w", err)
    }
-   path := filepath.Join(dir, s.filename())
-   f, err := os.Create(path)
+   data, err := json.MarshalIndent(s, "", "  ")
    if err != nil {
-       return fmt.Errorf("creating session file: %w", err)
-   }
-   defer f.Close()
-   enc := json.NewEncoder(f)
-   enc.SetIndent("", "  ")
-   if err := enc.Encode(s); err != nil {
        return fmt.Errorf("encoding session JSON: %w", err)
    }
+   if err := writeFile(filepath.Join(dir, s.filename()), append(data, '\n')); err != nil {
+       return fmt.Errorf("writing session file: %w", err)
+   }
    return nil
 }
 
@@ -82,7 +188,7 @@ func LoadSessions() ([]*Session, error) {
            continue
        }
        path := filepath.Join(dir, fi.Name())
-       data, err := os.ReadFile(path)
+       data, err := readFile(path)
        if err != nil {
            return nil, fmt.Errorf("reading session file %s: %w", fi.Name(), err)
        }
@@ -99,30 +205,141 @@ func LoadSessions() ([]*Session, error) {
    return sessions, nil
 }
 
-// sessionsDir returns the full path to the sessions directory (~/.ai-notes/sessions).
-func sessionsDir() (string, error) {
+// LoadSession reads a single session by ID from ~/.ai-notes/sessions.
+func LoadSession(id string) (*Session, error) {
+   dir, err := sessionsDir()
+   if err != nil {
+       return nil, err
+   }
+   data, err := readFile(filepath.Join(dir, id+".json"))
+   if err != nil {
+       return nil, fmt.Errorf("reading session file %s.json: %w", id, err)
+   }
+   var s Session
+   if err := json.Unmarshal(data, &s); err != nil {
+       return nil, fmt.Errorf("parsing session JSON %s.json: %w", id, err)
+   }
+   return &s, nil
+}
+
+// baseDir returns the data root (~/.ai-notes).
+func baseDir() (string, error) {
    home, err := os.UserHomeDir()
    if err != nil {
        return "", fmt.Errorf("could not determine home directory: %w", err)
    }
-   base := filepath.Join(home, baseDirName)
+   return filepath.Join(home, baseDirName), nil
+}
+
+// sessionsDir returns the full path to the sessions directory (~/.ai-notes/sessions).
+func sessionsDir() (string, error) {
+   base, err := baseDir()
+   if err != nil {
+       return "", err
+   }
    return filepath.Join(base, sessionsDirName), nil
 }
 
+// LastUpdated returns when the session last received a message, falling back to its creation time.
+func (s *Session) LastUpdated() time.Time {
+   if s.UpdatedAt.IsZero() {
+       return s.CreatedAt
+   }
+   return s.UpdatedAt
+}
+
+// DisplayTitle returns the session title, falling back to its ID.
+func (s *Session) DisplayTitle() string {
+   if s.Title != "" {
+       return s.Title
+   }
+   return s.ID
+}
+
 // filename returns the filename for the session: {ID}.json
 func (s *Session) filename() string {
    return s.ID + ".json"
 }
 
-// Delete removes the session JSON file from disk.
+// Delete removes the session JSON file and its attachment directory from disk.
 func (s *Session) Delete() error {
-    dir, err := sessionsDir()
-    if err != nil {
-        return err
-    }
-    path := filepath.Join(dir, s.filename())
-    if err := os.Remove(path); err != nil {
-        return fmt.Errorf("removing session file: %w", err)
-    }
-    return nil
-}
\ No newline at end of file
+   dir, err := sessionsDir()
+   if err != nil {
+       return err
+   }
+   path := filepath.Join(dir, s.filename())
+   if err := os.Remove(path); err != nil {
+       return fmt.Errorf("removing session file: %w", err)
+   }
+   if err := os.RemoveAll(filepath.Join(dir, s.ID)); err != nil {
+       return fmt.Errorf("removing session attachments: %w", err)
+   }
+   return nil
+}
+
+// attachmentsDir returns the directory holding the session's images (~/.ai-notes/sessions/{ID}).
+func (s *Session) attachmentsDir() (string, error) {
+   dir, err := sessionsDir()
+   if err != nil {
+       return "", err
+   }
+   return filepath.Join(dir, s.ID), nil
+}
+
+// AddImage copies the image at path into the session directory, named by its
+// sha256 hash, and returns an image Part referencing it. Images larger than
+// MaxImageSide are scaled down first; files over MaxImageBytes are refused.
+func (s *Session) AddImage(path string) (Part, error) {
+   fi, err := os.Stat(path)
+   if err != nil {
+       return Part{}, fmt.Errorf("reading image: %w", err)
+   }
+   if fi.Size() > MaxImageBytes {
+       return Part{}, fmt.Errorf("image %s is larger than %d MB", filepath.Base(path), MaxImageBytes>>20)
+   }
+   data, err := os.ReadFile(path)
+   if err != nil {
+       return Part{}, fmt.Errorf("reading image: %w", err)
+   }
+   cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+   if err != nil {
+       return Part{}, fmt.Errorf("decoding image %s: %w", filepath.Base(path), err)
+   }
+   data, cfg, format, err = fitImage(data, cfg, format)
+   if err != nil {
+       return Part{}, fmt.Errorf("image %s: %w", filepath.Base(path), err)
+   }
+   sum := sha256.Sum256(data)
+   hash := hex.EncodeToString(sum[:])
+   dir, err := s.attachmentsDir()
+   if err != nil {
+       return Part{}, err
+   }
+   if err := os.MkdirAll(dir, dirPerm); err != nil {
+       return Part{}, fmt.Errorf("creating session attachments dir: %w", err)
+   }
+   if err := writeFile(filepath.Join(dir, hash), data); err != nil {
+       return Part{}, fmt.Errorf("writing image: %w", err)
+   }
+   return Part{
+       Type:     PartImage,
+       Filename: filepath.Base(path),
+       Hash:     hash,
+       MimeType: "image/" + format,
+       Width:    cfg.Width,
+       Height:   cfg.Height,
+   }, nil
+}
+
+// ReadImage returns the bytes of an image part stored in the session directory.
+func (s *Session) ReadImage(p Part) ([]byte, error) {
+   dir, err := s.attachmentsDir()
+   if err != nil {
+       return nil, err
+   }
+   data, err := readFile(filepath.Join(dir, p.Hash))
+   if err != nil {
+       return nil, fmt.Errorf("reading image %s: %w", p.Filename, err)
+   }
+   return data, nil
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+   "sort"
+   "strings"
+)
+
+// MaxTags is the largest number of tags kept on a note.
+const MaxTags = 5
+
+// TagVocabulary returns the distinct tags used by notes, sorted.
+func TagVocabulary(notes []*Note) []string {
+   seen := make(map[string]bool)
+   var tags []string
+   for _, n := range notes {
+       for _, t := range n.Tags {
+           if !seen[t] {
+               seen[t] = true
+               tags = append(tags, t)
+           }
+       }
+   }
+   sort.Strings(tags)
+   return tags
+}
+
+// NormalizeTags cleans up tags and maps them onto vocabulary: tags are
+// lowercased, stripped of "#", use "-" between words, and reuse an existing
+// tag that differs only in separators or a plural "s". Duplicates are dropped
+// and at most MaxTags are kept.
+func NormalizeTags(tags, vocabulary []string) []string {
+   known := make(map[string]string)
+   for _, v := range vocabulary {
+       known[tagKey(v)] = v
+   }
+   seen := make(map[string]bool)
+   var out []string
+   for _, t := range tags {
+       t = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(t), "#")))
+       t = strings.Join(strings.FieldsFunc(t, func(r rune) bool {
+           return r == ' ' || r == '_' || r == '-' || r == ','
+       }), "-")
+       if t == "" {
+           continue
+       }
+       if v, ok := known[tagKey(t)]; ok {
+           t = v
+       }
+       if seen[t] {
+           continue
+       }
+       seen[t] = true
+       out = append(out, t)
+       if len(out) == MaxTags {
+           break
+       }
+   }
+   return out
+}
+
+// tagKey is the form under which two tags are considered the same.
+func tagKey(t string) string {
+   k := strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(t))
+   if len(k) > 3 {
+       k = strings.TrimSuffix(k, "s")
+   }
+   return k
+}
+
+// HasTag reports whether the note carries tag.
+func (n *Note) HasTag(tag string) bool {
+   for _, t := range n.Tags {
+       if t == tag {
+           return true
+       }
+   }
+   return false
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+   "encoding/json"
+   "fmt"
+   "os"
+   "path/filepath"
+   "sort"
+   "strings"
+)
+
+const (
+   templatesDirName = "templates"
+
+   // DefaultTemplate is the template used when none is chosen.
+   DefaultTemplate = "bullets"
+)
+
+// Template describes how a session is summarized into a note.
+// Prompt and Skeleton may reference variables as {{name}}; Variables holds
+// their default values. session_id and date are always provided.
+type Template struct {
+   Name        string            `json:"-"` // file name without extension
+   Description string            `json:"description"`
+   Prompt      string            `json:"prompt"`
+   Skeleton    string            `json:"skeleton,omitempty"`
+   Variables   map[string]string `json:"variables,omitempty"`
+}
+
+// builtinTemplates are written to the templates directory the first time it is read.
+var builtinTemplates = map[string]Template{
+   "bullets": {
+       Description: "Concise bullet-point notes",
+       Prompt:      "Please summarize the following conversation into concise bullet-point notes.",
+   },
+   "tldr": {
+       Description: "A short TL;DR paragraph",
+       Prompt:      "Summarize the following conversation as a TL;DR of at most {{sentences}} sentences.",
+       Variables:   map[string]string{"sentences": "3"},
+   },
+   "meeting-minutes": {
+       Description: "Meeting minutes with attendees, topics and follow-ups",
+       Prompt:      "Write meeting minutes for the following conversation held on {{date}}.",
+       Skeleton:    "## Participants\n\n## Topics discussed\n\n## Decisions\n\n## Follow-ups",
+   },
+   "decision-record": {
+       Description: "Architecture decision record",
+       Prompt:      "Write an architecture decision record capturing the decision reached in the following conversation.",
+       Skeleton:    "## Status\n\n## Context\n\n## Decision\n\n## Consequences",
+   },
+   "study-guide": {
+       Description: "Study guide for learning the material",
+       Prompt:      "Turn the following conversation into a study guide for a {{level}} learner.",
+       Skeleton:    "## Key concepts\n\n## Explanations\n\n## Examples\n\n## Review questions",
+       Variables:   map[string]string{"level": "beginner"},
+   },
+   "qa": {
+       Description: "Question and answer pairs",
+       Prompt:      "Rewrite the following conversation as a list of questions and their answers.",
+       Skeleton:    "**Q:** ...\n**A:** ...",
+   },
+}
+
+// templatesDir returns the full path to the templates directory (~/.ai-notes/templates).
+func templatesDir() (string, error) {
+   base, err := baseDir()
+   if err != nil {
+       return "", err
+   }
+   return filepath.Join(base, templatesDirName), nil
+}
+
+// LoadTemplates reads all note templates from ~/.ai-notes/templates, sorted by name.
+// If the directory does not exist it is created and seeded with the built-in templates.
+func LoadTemplates() ([]*Template, error) {
+   dir, err := templatesDir()
+   if err != nil {
+       return nil, err
+   }
+   if _, err := os.Stat(dir); os.IsNotExist(err) {
+       if err := writeBuiltinTemplates(dir); err != nil {
+           return nil, err
+       }
+   }
+   files, err := os.ReadDir(dir)
+   if err != nil {
+       return nil, fmt.Errorf("reading templates dir: %w", err)
+   }
+   var templates []*Template
+   for _, fi := range files {
+       if fi.IsDir() || filepath.Ext(fi.Name()) != ".json" {
+           continue
+       }
+       data, err := os.ReadFile(filepath.Join(dir, fi.Name()))
+       if err != nil {
+           return nil, fmt.Errorf("reading template file %s: %w", fi.Name(), err)
+       }
+       var t Template
+       if err := json.Unmarshal(data, &t); err != nil {
+           return nil, fmt.Errorf("parsing template JSON %s: %w", fi.Name(), err)
+       }
+       t.Name = strings.TrimSuffix(fi.Name(), ".json")
+       templates = append(templates, &t)
+   }
+   sort.Slice(templates, func(i, j int) bool {
+       return templates[i].Name < templates[j].Name
+   })
+   return templates, nil
+}
+
+// writeBuiltinTemplates creates dir and writes every built-in template into it.
+func writeBuiltinTemplates(dir string) error {
+   if err := os.MkdirAll(dir, 0o755); err != nil {
+       return fmt.Errorf("creating templates dir: %w", err)
+   }
+   for name, t := range builtinTemplates {
+       data, err := json.MarshalIndent(t, "", "  ")
+       if err != nil {
+           return fmt.Errorf("encoding template %s: %w", name, err)
+       }
+       if err := os.WriteFile(filepath.Join(dir, name+".json"), data, 0o644); err != nil {
+           return fmt.Errorf("writing template %s: %w", name, err)
+       }
+   }
+   return nil
+}
+
+// SystemPrompt renders the template's prompt and skeleton with its variables.
+// vars override the template defaults.
+func (t *Template) SystemPrompt(vars map[string]string) string {
+   var pairs []string
+   for k, v := range t.Variables {
+       if _, ok := vars[k]; !ok {
+           pairs = append(pairs, "{{"+k+"}}", v)
+       }
+   }
+   for k, v := range vars {
+       pairs = append(pairs, "{{"+k+"}}", v)
+   }
+   r := strings.NewReplacer(pairs...)
+   prompt := r.Replace(t.Prompt)
+   if t.Skeleton != "" {
+       prompt += "\n\nFormat the output using this structure:\n\n" + r.Replace(t.Skeleton)
+   }
+   return prompt
+}
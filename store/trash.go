@@ -0,0 +1,289 @@
+package store
+
+import (
+   "encoding/json"
+   "fmt"
+   "os"
+   "path/filepath"
+   "sort"
+   "strings"
+   "time"
+)
+
+const (
+   trashDirName = "trash"
+
+   // trash item kinds
+   TrashSession = "session"
+   TrashNote    = "note"
+)
+
+// TrashItem is a session or note that was moved to ~/.ai-notes/trash.
+// The trashed file's modification time records when it was deleted.
+type TrashItem struct {
+   Kind      string // TrashSession or TrashNote
+   ID        string
+   Title     string
+   DeletedAt time.Time
+
+   // name is the base name of the trashed files: the ID, followed by
+   // trashSuffixSep and a number if an item with that ID was already in
+   // the trash.
+   name string
+}
+
+// trashSuffixSep separates an ID from the number that tells trashed items
+// with the same ID apart. IDs never contain it.
+const trashSuffixSep = "~"
+
+// trashDir returns the trash directory for a kind of item (~/.ai-notes/trash/{sessions,notes}).
+func trashDir(kind string) (string, error) {
+   base, err := baseDir()
+   if err != nil {
+       return "", err
+   }
+   sub := sessionsDirName
+   if kind == TrashNote {
+       sub = notesDirName
+   }
+   return filepath.Join(base, trashDirName, sub), nil
+}
+
+// paths returns the item's files as (live, trashed) pairs. A session has its
+// JSON file and its attachments directory, a note its markdown file and its
+// history directory.
+func (t *TrashItem) paths() ([][2]string, error) {
+   trash, err := trashDir(t.Kind)
+   if err != nil {
+       return nil, err
+   }
+   if t.Kind == TrashNote {
+       dir, err := notesDir()
+       if err != nil {
+           return nil, err
+       }
+       history, err := historyDir(t.ID)
+       if err != nil {
+           return nil, err
+       }
+       return [][2]string{
+           {filepath.Join(dir, t.ID+".md"), filepath.Join(trash, t.trashName()+".md")},
+           {history, filepath.Join(trash, t.trashName())},
+       }, nil
+   }
+   dir, err := sessionsDir()
+   if err != nil {
+       return nil, err
+   }
+   return [][2]string{
+       {filepath.Join(dir, t.ID+".json"), filepath.Join(trash, t.trashName()+".json")},
+       {filepath.Join(dir, t.ID), filepath.Join(trash, t.trashName())},
+   }, nil
+}
+
+// trashName returns the base name of the item's files in the trash.
+func (t *TrashItem) trashName() string {
+   if t.name == "" {
+       return t.ID
+   }
+   return t.name
+}
+
+// pickTrashName names the item's files in the trash so that they replace
+// nothing: a note deleted, restored and deleted again, or a new note given
+// the ID of a trashed one, keeps every trashed version.
+func (t *TrashItem) pickTrashName() error {
+   for i := 1; ; i++ {
+       t.name = t.ID
+       if i > 1 {
+           t.name = fmt.Sprintf("%s%s%d", t.ID, trashSuffixSep, i)
+       }
+       pairs, err := t.paths()
+       if err != nil {
+           return err
+       }
+       taken := false
+       for _, p := range pairs {
+           if _, err := os.Lstat(p[1]); err == nil {
+               taken = true
+           }
+       }
+       if !taken {
+           return nil
+       }
+   }
+}
+
+// moveToTrash moves the item's files into the trash and stamps the deletion time.
+func (t *TrashItem) moveToTrash() error {
+   if err := t.pickTrashName(); err != nil {
+       return err
+   }
+   pairs, err := t.paths()
+   if err != nil {
+       return err
+   }
+   if err := os.MkdirAll(filepath.Dir(pairs[0][1]), dirPerm); err != nil {
+       return fmt.Errorf("creating trash dir: %w", err)
+   }
+   for i, p := range pairs {
+       if err := os.Rename(p[0], p[1]); err != nil {
+           // only the first file (the item itself) has to exist
+           if i > 0 && os.IsNotExist(err) {
+               continue
+           }
+           return fmt.Errorf("moving %s to trash: %w", t.Kind, err)
+       }
+   }
+   t.DeletedAt = time.Now()
+   if err := os.Chtimes(pairs[0][1], t.DeletedAt, t.DeletedAt); err != nil {
+       return fmt.Errorf("stamping trashed %s: %w", t.Kind, err)
+   }
+   return nil
+}
+
+// Trash moves the session file and its attachments into the trash.
+func (s *Session) Trash() (*TrashItem, error) {
+   t := &TrashItem{Kind: TrashSession, ID: s.ID, Title: s.DisplayTitle()}
+   if err := t.moveToTrash(); err != nil {
+       return nil, err
+   }
+   return t, nil
+}
+
+// Trash moves the note file into the trash.
+func (n *Note) Trash() (*TrashItem, error) {
+   if n.External != "" {
+       return nil, ErrReadOnly
+   }
+   t := &TrashItem{Kind: TrashNote, ID: n.ID, Title: n.Title}
+   if err := t.moveToTrash(); err != nil {
+       return nil, err
+   }
+   return t, nil
+}
+
+// Restore moves a trashed item back to where it was deleted from.
+func (t *TrashItem) Restore() error {
+   pairs, err := t.paths()
+   if err != nil {
+       return err
+   }
+   if _, err := os.Stat(pairs[0][0]); err == nil {
+       return fmt.Errorf("cannot restore %s %s: it already exists", t.Kind, t.ID)
+   }
+   if err := os.MkdirAll(filepath.Dir(pairs[0][0]), dirPerm); err != nil {
+       return fmt.Errorf("creating %s dir: %w", t.Kind, err)
+   }
+   for i, p := range pairs {
+       if err := os.Rename(p[1], p[0]); err != nil {
+           if i > 0 && os.IsNotExist(err) {
+               continue
+           }
+           return fmt.Errorf("restoring %s: %w", t.Kind, err)
+       }
+   }
+   return nil
+}
+
+// Purge permanently deletes a trashed item.
+func (t *TrashItem) Purge() error {
+   pairs, err := t.paths()
+   if err != nil {
+       return err
+   }
+   for _, p := range pairs {
+       if err := os.RemoveAll(p[1]); err != nil {
+           return fmt.Errorf("purging %s: %w", t.Kind, err)
+       }
+   }
+   return nil
+}
+
+// LoadTrash lists every trashed session and note, most recently deleted first.
+func LoadTrash() ([]*TrashItem, error) {
+   var items []*TrashItem
+   for _, kind := range []string{TrashSession, TrashNote} {
+       dir, err := trashDir(kind)
+       if err != nil {
+           return nil, err
+       }
+       files, err := os.ReadDir(dir)
+       if err != nil {
+           if os.IsNotExist(err) {
+               continue
+           }
+           return nil, fmt.Errorf("reading trash dir: %w", err)
+       }
+       for _, fi := range files {
+           ext := ".json"
+           if kind == TrashNote {
+               ext = ".md"
+           }
+           if fi.IsDir() || filepath.Ext(fi.Name()) != ext {
+               continue
+           }
+           info, err := fi.Info()
+           if err != nil {
+               return nil, fmt.Errorf("reading trash item %s: %w", fi.Name(), err)
+           }
+           name := strings.TrimSuffix(fi.Name(), ext)
+           id, _, _ := strings.Cut(name, trashSuffixSep)
+           item := &TrashItem{
+               Kind:      kind,
+               ID:        id,
+               DeletedAt: info.ModTime(),
+               name:      name,
+           }
+           item.Title = trashedTitle(filepath.Join(dir, fi.Name()), kind, item.ID)
+           items = append(items, item)
+       }
+   }
+   sort.Slice(items, func(i, j int) bool {
+       return items[i].DeletedAt.After(items[j].DeletedAt)
+   })
+   return items, nil
+}
+
+// trashedTitle reads the display title of a trashed file, falling back to id.
+func trashedTitle(path, kind, id string) string {
+   if kind == TrashNote {
+       if n, err := readNote(path, id); err == nil {
+           return n.Title
+       }
+       return id
+   }
+   data, err := readFile(path)
+   if err != nil {
+       return id
+   }
+   var s Session
+   if err := json.Unmarshal(data, &s); err != nil {
+       return id
+   }
+   return s.DisplayTitle()
+}
+
+// PurgeTrash permanently deletes items that have been in the trash longer
+// than retention. It returns how many items were purged. A retention of
+// zero or less purges nothing.
+func PurgeTrash(retention time.Duration) (int, error) {
+   if retention <= 0 {
+       return 0, nil
+   }
+   items, err := LoadTrash()
+   if err != nil {
+       return 0, err
+   }
+   purged := 0
+   for _, item := range items {
+       if time.Since(item.DeletedAt) < retention {
+           continue
+       }
+       if err := item.Purge(); err != nil {
+           return purged, err
+       }
+       purged++
+   }
+   return purged, nil
+}
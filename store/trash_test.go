@@ -0,0 +1,62 @@
+package store
+
+import (
+   "testing"
+)
+
+func TestTrashKeepsItemsWithTheSameID(t *testing.T) {
+   tempStore(t)
+   n, _ := saveNote(t, "first")
+   if _, err := n.Trash(); err != nil {
+       t.Fatal(err)
+   }
+   // a note saved again under the trashed note's ID
+   n.Body = "second"
+   if _, err := n.Save(); err != nil {
+       t.Fatal(err)
+   }
+   if _, err := n.Trash(); err != nil {
+       t.Fatal(err)
+   }
+
+   items, err := LoadTrash()
+   if err != nil {
+       t.Fatal(err)
+   }
+   if len(items) != 2 {
+       t.Fatalf("got %d trashed items, want 2", len(items))
+   }
+   bodies := map[string]bool{}
+   for _, item := range items {
+       if item.ID != n.ID {
+           t.Errorf("trashed item ID = %s, want %s", item.ID, n.ID)
+       }
+       // each version is restored under the note's own ID
+       if err := item.Restore(); err != nil {
+           t.Fatal(err)
+       }
+       restored, err := LoadNote(n.ID)
+       if err != nil {
+           t.Fatal(err)
+       }
+       bodies[restored.Body] = true
+       if _, err := restored.Trash(); err != nil {
+           t.Fatal(err)
+       }
+   }
+   if !bodies["first"] || !bodies["second"] {
+       t.Errorf("restored bodies = %v, want first and second", bodies)
+   }
+
+   if items, err = LoadTrash(); err != nil {
+       t.Fatal(err)
+   }
+   for _, item := range items {
+       if err := item.Purge(); err != nil {
+           t.Fatal(err)
+       }
+   }
+   if items, err = LoadTrash(); err != nil || len(items) != 0 {
+       t.Errorf("trash after purging = %d items, %v; want it empty", len(items), err)
+   }
+}
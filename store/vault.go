@@ -0,0 +1,238 @@
+package store
+
+import (
+   "crypto/sha256"
+   "encoding/hex"
+   "errors"
+   "fmt"
+   "io/fs"
+   "os"
+   "path/filepath"
+   "sort"
+   "strings"
+   "time"
+)
+
+// ErrReadOnly is returned when changing a note indexed from a vault.
+var ErrReadOnly = errors.New("note belongs to a vault and is read-only")
+
+// vaultSkipDirs are vault folders holding tool data rather than notes.
+var vaultSkipDirs = map[string]bool{"logseq": true, "node_modules": true}
+
+// LoadVault reads every markdown file below dir as a read-only note. Notes
+// are filed in a notebook named after the vault and the file's folder, take
+// their title, tags and creation date from frontmatter (YAML, or Logseq
+// "key:: value" properties) and fall back to the file name and modification
+// time. Hidden folders such as .obsidian and .trash are skipped.
+func LoadVault(dir string) ([]*Note, error) {
+   root, err := filepath.Abs(dir)
+   if err != nil {
+       return nil, err
+   }
+   var notes []*Note
+   err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+       if err != nil {
+           return err
+       }
+       if d.IsDir() {
+           if path != root && (strings.HasPrefix(d.Name(), ".") || vaultSkipDirs[d.Name()]) {
+               return filepath.SkipDir
+           }
+           return nil
+       }
+       if !strings.EqualFold(filepath.Ext(path), ".md") {
+           return nil
+       }
+       n, err := readVaultNote(root, path)
+       if err != nil {
+           return err
+       }
+       notes = append(notes, n)
+       return nil
+   })
+   if err != nil {
+       return nil, fmt.Errorf("reading vault %s: %w", dir, err)
+   }
+   return notes, nil
+}
+
+// LoadAllNotes returns the notes in ~/.ai-notes/notes together with the
+// notes of every vault, newest first. Vault notes that were imported are
+// only listed once, as the imported note.
+func LoadAllNotes(vaults []string) ([]*Note, error) {
+   notes, err := LoadNotes()
+   if err != nil {
+       return nil, err
+   }
+   owned := make(map[string]bool, len(notes))
+   for _, n := range notes {
+       owned[n.ID] = true
+   }
+   for _, dir := range vaults {
+       vault, err := LoadVault(dir)
+       if err != nil {
+           return nil, err
+       }
+       for _, n := range vault {
+           if !owned[n.ID] {
+               notes = append(notes, n)
+           }
+       }
+   }
+   sort.Slice(notes, func(i, j int) bool {
+       return notes[i].CreatedAt.After(notes[j].CreatedAt)
+   })
+   return notes, nil
+}
+
+// readVaultNote parses the markdown file at path inside the vault root.
+func readVaultNote(root, path string) (*Note, error) {
+   data, err := os.ReadFile(path)
+   if err != nil {
+       return nil, err
+   }
+   info, err := os.Stat(path)
+   if err != nil {
+       return nil, err
+   }
+   rel, err := filepath.Rel(root, path)
+   if err != nil {
+       return nil, err
+   }
+   meta, body := parseVaultMeta(string(data))
+   name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+   n := &Note{
+       ID:        VaultNoteID(root, rel),
+       Title:     name,
+       Body:      strings.TrimSpace(body),
+       CreatedAt: info.ModTime(),
+       External:  path,
+   }
+   notebook := filepath.Base(root)
+   if d := filepath.Dir(rel); d != "." {
+       notebook += "/" + filepath.ToSlash(d)
+   }
+   n.Notebook = NormalizeNotebook(notebook)
+   if t := first(meta["title"]); t != "" {
+       n.Title = t
+   }
+   for _, key := range []string{"created", "date", "created_at", "created-at"} {
+       if t, ok := parseVaultTime(first(meta[key])); ok {
+           n.CreatedAt = t
+           break
+       }
+   }
+   for _, key := range []string{"tags", "tag"} {
+       for _, t := range meta[key] {
+           if t = strings.TrimPrefix(strings.TrimSpace(t), "#"); t != "" {
+               n.Tags = append(n.Tags, strings.ToLower(t))
+           }
+       }
+   }
+   // a leading "# Title" heading repeats the title
+   if rest, ok := strings.CutPrefix(n.Body, "# "+n.Title); ok {
+       n.Body = strings.TrimSpace(rest)
+   }
+   return n, nil
+}
+
+// VaultNoteID derives a stable note ID from a file's path inside its vault,
+// so re-indexing and re-importing find the same note. The vault's absolute
+// path is part of it, so vaults with the same folder name don't collide.
+func VaultNoteID(root, rel string) string {
+   if abs, err := filepath.Abs(root); err == nil {
+       root = abs
+   }
+   sum := sha256.Sum256([]byte(filepath.ToSlash(root) + "/" + filepath.ToSlash(rel)))
+   return "vault-" + hex.EncodeToString(sum[:6])
+}
+
+// parseVaultMeta splits leading metadata from a vault file: a "---"
+// delimited YAML block or Logseq "key:: value" lines. Values may be
+// "[a, b]" lists, "- item" lines below their key or, for tags,
+// comma-separated. Keys are lowercased.
+func parseVaultMeta(text string) (map[string][]string, string) {
+   meta := make(map[string][]string)
+   lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+   if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+       key := ""
+       for i := 1; i < len(lines); i++ {
+           line := lines[i]
+           if strings.TrimSpace(line) == "---" {
+               return meta, strings.Join(lines[i+1:], "\n")
+           }
+           if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok && key != "" {
+               meta[key] = append(meta[key], unquote(item))
+               continue
+           }
+           k, v, ok := strings.Cut(line, ":")
+           if !ok {
+               continue
+           }
+           key = strings.ToLower(strings.TrimSpace(k))
+           meta[key] = splitMetaValue(key, v)
+       }
+       // no closing delimiter: not frontmatter
+       return map[string][]string{}, text
+   }
+   i := 0
+   for ; i < len(lines); i++ {
+       k, v, ok := strings.Cut(strings.TrimSpace(lines[i]), ":: ")
+       if !ok || strings.ContainsAny(k, " \t") {
+           break
+       }
+       key := strings.ToLower(k)
+       meta[key] = splitMetaValue(key, v)
+   }
+   return meta, strings.Join(lines[i:], "\n")
+}
+
+// splitMetaValue parses a metadata value into its items.
+func splitMetaValue(key, v string) []string {
+   v = strings.TrimSpace(v)
+   if v == "" {
+       return nil
+   }
+   if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+       v = strings.Trim(v, "[]")
+   } else if key != "tags" && key != "tag" && key != "aliases" {
+       return []string{unquote(v)}
+   }
+   var items []string
+   for _, item := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' }) {
+       if item = unquote(strings.TrimSpace(item)); item != "" {
+           items = append(items, item)
+       }
+   }
+   return items
+}
+
+// unquote strips matching single or double quotes and Logseq [[ ]] brackets.
+func unquote(s string) string {
+   s = strings.TrimSpace(s)
+   if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+       s = s[1 : len(s)-1]
+   }
+   if strings.HasPrefix(s, "[[") && strings.HasSuffix(s, "]]") {
+       s = s[2 : len(s)-2]
+   }
+   return s
+}
+
+// first returns the first item of a metadata value, or "".
+func first(items []string) string {
+   if len(items) == 0 {
+       return ""
+   }
+   return items[0]
+}
+
+// parseVaultTime parses the date formats common in vault frontmatter.
+func parseVaultTime(s string) (time.Time, bool) {
+   for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "Jan 2, 2006", "Jan 2nd, 2006"} {
+       if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+           return t, true
+       }
+   }
+   return time.Time{}, false
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+   "os"
+   "path/filepath"
+   "testing"
+)
+
+func TestVaultNoteIDsOfSameNamedVaults(t *testing.T) {
+   tempStore(t)
+   dir := t.TempDir()
+   var vaults []string
+   for _, parent := range []string{"work", "home"} {
+       vault := filepath.Join(dir, parent, "notes")
+       if err := os.MkdirAll(vault, 0o755); err != nil {
+           t.Fatal(err)
+       }
+       if err := os.WriteFile(filepath.Join(vault, "Todo.md"), []byte(parent+" todo\n"), 0o644); err != nil {
+           t.Fatal(err)
+       }
+       vaults = append(vaults, vault)
+   }
+   notes, err := LoadAllNotes(vaults)
+   if err != nil {
+       t.Fatal(err)
+   }
+   if len(notes) != 2 {
+       t.Fatalf("got %d notes, want 2", len(notes))
+   }
+   if notes[0].ID == notes[1].ID {
+       t.Fatalf("both vaults' Todo.md have ID %s", notes[0].ID)
+   }
+
+   // the ID does not depend on how the vault path is written
+   unclean := filepath.Join(dir, "work") + "/../work/notes/"
+   if got, want := VaultNoteID(unclean, "Todo.md"), VaultNoteID(vaults[0], "Todo.md"); got != want {
+       t.Errorf("ID for %s = %s, want %s", unclean, got, want)
+   }
+}
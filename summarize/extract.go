@@ -0,0 +1,143 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	goopenai "github.com/sashabaranov/go-openai"
+	"github.com/sergey-suslov/ai-notes/store"
+	"github.com/sergey-suslov/ai-notes/util"
+)
+
+// maxExtractAttempts bounds how often a malformed JSON reply is retried.
+const maxExtractAttempts = 3
+
+// extractPrompt is appended to the template prompt for structured notes.
+const extractPrompt = "Reply with a JSON object describing the conversation: a short title, " +
+	"1-5 lowercase tags, the summary written as markdown following the instructions above, " +
+	"action items with their owner and due date (YYYY-MM-DD) when known or empty strings otherwise, " +
+	"the decisions that were made and the questions left open."
+
+// extractSchema is the JSON schema the model's reply must satisfy.
+var extractSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "title": {"type": "string"},
+    "tags": {"type": "array", "items": {"type": "string"}},
+    "summary": {"type": "string"},
+    "action_items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "text": {"type": "string"},
+          "owner": {"type": "string"},
+          "due": {"type": "string"}
+        },
+        "required": ["text", "owner", "due"],
+        "additionalProperties": false
+      }
+    },
+    "decisions": {"type": "array", "items": {"type": "string"}},
+    "open_questions": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["title", "tags", "summary", "action_items", "decisions", "open_questions"],
+  "additionalProperties": false
+}`)
+
+// JSONCompleter is the part of the provider client used for structured extraction.
+type JSONCompleter interface {
+	Completer
+	ChatCompletionJSON(ctx context.Context, messages []goopenai.ChatCompletionMessage, model string, schema *goopenai.ChatCompletionResponseFormatJSONSchema) (string, error)
+}
+
+// Extraction is the structured form of a note.
+type Extraction struct {
+	Title         string             `json:"title"`
+	Tags          []string           `json:"tags"`
+	Summary       string             `json:"summary"`
+	ActionItems   []store.ActionItem `json:"action_items"`
+	Decisions     []string           `json:"decisions"`
+	OpenQuestions []string           `json:"open_questions"`
+}
+
+// Extract asks c for a structured note of msgs, guided by the system prompt.
+// Replies that are not valid JSON or fail validation are retried with the
+// validation error as feedback. Transcripts over the token budget are first
+// condensed with Summarize.
+func Extract(ctx context.Context, c JSONCompleter, system string, msgs []goopenai.ChatCompletionMessage, opts Options) (*Extraction, error) {
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	total := util.EstimateTokens(system)
+	for _, msg := range msgs {
+		total += tokens(msg)
+	}
+	if total > opts.MaxTokens {
+		condensed, err := Summarize(ctx, c, system, msgs, opts)
+		if err != nil {
+			return nil, err
+		}
+		msgs = []goopenai.ChatCompletionMessage{{Role: goopenai.ChatMessageRoleUser, Content: condensed}}
+	}
+	schema := &goopenai.ChatCompletionResponseFormatJSONSchema{Name: "note", Schema: extractSchema, Strict: true}
+	req := withSystem(system+"\n\n"+extractPrompt, msgs)
+	var lastErr error
+	for attempt := 0; attempt < maxExtractAttempts; attempt++ {
+		reply, err := c.ChatCompletionJSON(ctx, req, opts.Model, schema)
+		if err != nil {
+			return nil, err
+		}
+		e, err := parseExtraction(reply)
+		if err == nil {
+			return e, nil
+		}
+		lastErr = err
+		req = append(req,
+			goopenai.ChatCompletionMessage{Role: goopenai.ChatMessageRoleAssistant, Content: reply},
+			goopenai.ChatCompletionMessage{Role: goopenai.ChatMessageRoleUser, Content: "That reply was invalid: " + err.Error() + ". Reply again with only the corrected JSON object."},
+		)
+	}
+	return nil, fmt.Errorf("structured note still invalid after %d attempts: %w", maxExtractAttempts, lastErr)
+}
+
+// parseExtraction decodes and validates a JSON reply.
+func parseExtraction(reply string) (*Extraction, error) {
+	dec := json.NewDecoder(strings.NewReader(reply))
+	dec.DisallowUnknownFields()
+	var e Extraction
+	if err := dec.Decode(&e); err != nil {
+		return nil, fmt.Errorf("malformed JSON: %w", err)
+	}
+	if strings.TrimSpace(e.Title) == "" {
+		return nil, errors.New("title is empty")
+	}
+	if strings.TrimSpace(e.Summary) == "" {
+		return nil, errors.New("summary is empty")
+	}
+	for i, it := range e.ActionItems {
+		if strings.TrimSpace(it.Text) == "" {
+			return nil, fmt.Errorf("action item %d has no text", i+1)
+		}
+		if it.Due != "" {
+			if _, err := time.Parse("2006-01-02", it.Due); err != nil {
+				return nil, fmt.Errorf("action item %d due date %q is not YYYY-MM-DD", i+1, it.Due)
+			}
+		}
+	}
+	return &e, nil
+}
+
+// Apply copies the extracted fields onto note.
+func (e *Extraction) Apply(note *store.Note) {
+	note.Title = e.Title
+	note.Body = e.Summary
+	note.Tags = e.Tags
+	note.ActionItems = e.ActionItems
+	note.Decisions = e.Decisions
+	note.OpenQuestions = e.OpenQuestions
+}
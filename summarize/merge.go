@@ -0,0 +1,33 @@
+package summarize
+
+import (
+	"context"
+	"fmt"
+
+	goopenai "github.com/sashabaranov/go-openai"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// mergeNotesPrompt asks the model to consolidate several notes.
+const mergeNotesPrompt = "Consolidate the following notes into a single markdown note. " +
+	"Combine related points, remove duplicated information and keep every distinct fact, decision and action item."
+
+// NoteMessages returns the messages MergeNotes sends for notes, one per
+// note, so that they can be redacted first.
+func NoteMessages(notes []*store.Note) []goopenai.ChatCompletionMessage {
+	msgs := make([]goopenai.ChatCompletionMessage, len(notes))
+	for i, n := range notes {
+		msgs[i] = goopenai.ChatCompletionMessage{
+			Role:    goopenai.ChatMessageRoleUser,
+			Content: fmt.Sprintf("# Note %d: %s\n\n%s", i+1, n.Title, n.Markdown()),
+		}
+	}
+	return msgs
+}
+
+// MergeNotes asks c to consolidate the notes in msgs, as built by
+// NoteMessages, into one deduplicated body. Notes that do not fit into one
+// request are merged in parts, as in Summarize.
+func MergeNotes(ctx context.Context, c Completer, msgs []goopenai.ChatCompletionMessage, model string) (string, error) {
+	return Summarize(ctx, c, mergeNotesPrompt, msgs, Options{Model: model})
+}
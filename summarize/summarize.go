@@ -0,0 +1,213 @@
+// Package summarize condenses chat transcripts into notes. Transcripts that do
+// not fit into a single request are summarized with a map-reduce pass: the
+// transcript is split into token-bounded chunks, each chunk is summarized
+// concurrently, and the partial summaries are merged in a final request.
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	goopenai "github.com/sashabaranov/go-openai"
+	"github.com/sergey-suslov/ai-notes/util"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DefaultMaxTokens is the token budget of a single summarization request.
+	DefaultMaxTokens = 60000
+	// DefaultWorkers is how many chunks are summarized concurrently.
+	DefaultWorkers = 4
+
+	// imageTokens is the rough cost charged for every image part.
+	imageTokens = 1000
+
+	mapPrompt = "The following messages are part %d of %d of a longer conversation. " +
+		"Summarize them as notes, keeping every fact, decision, open question and code reference; " +
+		"your summary will be merged with the summaries of the other parts."
+	mergePrompt = "The conversation was too long to read at once, so it was summarized in parts. " +
+		"The partial summaries follow in order. Merge them, removing duplication."
+)
+
+// Completer is the part of the provider client used for summarization.
+// *openai.Client implements it; tests can substitute a fake.
+type Completer interface {
+	ChatCompletion(ctx context.Context, messages []goopenai.ChatCompletionMessage, model string) (string, error)
+}
+
+// Options tune a summarization run. Zero values select the defaults.
+type Options struct {
+	Model     string
+	MaxTokens int // budget per request, including the system prompt
+	Workers   int // concurrent chunk requests
+	// Progress, if set, is called after each chunk is summarized. It may be
+	// called from several goroutines, but never concurrently.
+	Progress func(done, total int)
+}
+
+// Summarize asks c to summarize msgs following the system prompt. If the
+// request would exceed opts.MaxTokens the transcript is summarized in chunks
+// and the partial summaries are merged with system as the final instruction.
+func Summarize(ctx context.Context, c Completer, system string, msgs []goopenai.ChatCompletionMessage, opts Options) (string, error) {
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	budget := opts.MaxTokens - util.EstimateTokens(system) - util.EstimateTokens(mapPrompt)
+	if budget <= 0 {
+		return "", fmt.Errorf("system prompt exceeds token budget of %d", opts.MaxTokens)
+	}
+	chunks := Chunk(msgs, budget)
+	if len(chunks) <= 1 {
+		return c.ChatCompletion(ctx, withSystem(system, msgs), opts.Model)
+	}
+	partials, err := summarizeChunks(ctx, c, chunks, opts)
+	if err != nil {
+		return "", err
+	}
+	return merge(ctx, c, system, partials, budget, opts)
+}
+
+// summarizeChunks runs the map step with at most opts.Workers concurrent requests.
+func summarizeChunks(ctx context.Context, c Completer, chunks [][]goopenai.ChatCompletionMessage, opts Options) ([]string, error) {
+	partials := make([]string, len(chunks))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.Workers)
+	var mu sync.Mutex
+	done := 0
+	for i, chunk := range chunks {
+		g.Go(func() error {
+			sys := fmt.Sprintf(mapPrompt, i+1, len(chunks))
+			out, err := c.ChatCompletion(ctx, withSystem(sys, chunk), opts.Model)
+			if err != nil {
+				return fmt.Errorf("summarizing part %d of %d: %w", i+1, len(chunks), err)
+			}
+			partials[i] = out
+			if opts.Progress != nil {
+				mu.Lock()
+				done++
+				opts.Progress(done, len(chunks))
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return partials, nil
+}
+
+// merge runs the reduce step. If the partial summaries themselves exceed the
+// budget they are merged in groups first, until one request suffices.
+func merge(ctx context.Context, c Completer, system string, partials []string, budget int, opts Options) (string, error) {
+	for {
+		groups := groupPartials(partials, budget)
+		if len(groups) == 1 {
+			return c.ChatCompletion(ctx, []goopenai.ChatCompletionMessage{
+				{Role: goopenai.ChatMessageRoleSystem, Content: system + "\n\n" + mergePrompt},
+				{Role: goopenai.ChatMessageRoleUser, Content: groups[0]},
+			}, opts.Model)
+		}
+		if len(groups) >= len(partials) {
+			return "", fmt.Errorf("partial summaries do not fit the token budget of %d", opts.MaxTokens)
+		}
+		merged := make([]string, len(groups))
+		for i, g := range groups {
+			out, err := c.ChatCompletion(ctx, []goopenai.ChatCompletionMessage{
+				{Role: goopenai.ChatMessageRoleSystem, Content: mergePrompt},
+				{Role: goopenai.ChatMessageRoleUser, Content: g},
+			}, opts.Model)
+			if err != nil {
+				return "", fmt.Errorf("merging partial summaries: %w", err)
+			}
+			merged[i] = out
+		}
+		partials = merged
+	}
+}
+
+// groupPartials joins consecutive partial summaries into texts of at most budget tokens.
+func groupPartials(partials []string, budget int) []string {
+	var groups []string
+	var cur strings.Builder
+	for i, p := range partials {
+		section := fmt.Sprintf("## Part %d\n\n%s\n\n", i+1, p)
+		if cur.Len() > 0 && util.EstimateTokens(cur.String()+section) > budget {
+			groups = append(groups, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(section)
+	}
+	if cur.Len() > 0 {
+		groups = append(groups, cur.String())
+	}
+	return groups
+}
+
+// Chunk splits msgs into consecutive groups of at most budget tokens each.
+// A message that alone exceeds the budget is split into several text messages.
+func Chunk(msgs []goopenai.ChatCompletionMessage, budget int) [][]goopenai.ChatCompletionMessage {
+	var chunks [][]goopenai.ChatCompletionMessage
+	var cur []goopenai.ChatCompletionMessage
+	used := 0
+	flush := func() {
+		if len(cur) > 0 {
+			chunks = append(chunks, cur)
+			cur, used = nil, 0
+		}
+	}
+	for _, msg := range msgs {
+		n := tokens(msg)
+		if n > budget {
+			flush()
+			for _, piece := range util.SplitTokens(text(msg), budget) {
+				chunks = append(chunks, []goopenai.ChatCompletionMessage{{Role: msg.Role, Content: piece}})
+			}
+			continue
+		}
+		if used+n > budget {
+			flush()
+		}
+		cur = append(cur, msg)
+		used += n
+	}
+	flush()
+	return chunks
+}
+
+// withSystem prepends a system message with prompt to msgs.
+func withSystem(prompt string, msgs []goopenai.ChatCompletionMessage) []goopenai.ChatCompletionMessage {
+	out := make([]goopenai.ChatCompletionMessage, 0, len(msgs)+1)
+	out = append(out, goopenai.ChatCompletionMessage{Role: goopenai.ChatMessageRoleSystem, Content: prompt})
+	return append(out, msgs...)
+}
+
+// text returns the textual content of msg, including text parts of MultiContent.
+func text(msg goopenai.ChatCompletionMessage) string {
+	if len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+	var b strings.Builder
+	for _, p := range msg.MultiContent {
+		if p.Type == goopenai.ChatMessagePartTypeText {
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String()
+}
+
+// tokens estimates the cost of msg, charging a flat amount per image.
+func tokens(msg goopenai.ChatCompletionMessage) int {
+	n := util.EstimateTokens(text(msg))
+	for _, p := range msg.MultiContent {
+		if p.Type == goopenai.ChatMessagePartTypeImageURL {
+			n += imageTokens
+		}
+	}
+	return n
+}
@@ -0,0 +1,203 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	goopenai "github.com/sashabaranov/go-openai"
+)
+
+// fakeCompleter answers map requests with "summary N" for part N and merge
+// requests with the text it was asked to merge. It records the highest
+// number of requests in flight at once.
+type fakeCompleter struct {
+	// delay returns how long to take for part n of total
+	delay func(n, total int) time.Duration
+	// fail, if set, makes the request for part n fail
+	fail func(n int) error
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	calls    int
+}
+
+func (f *fakeCompleter) ChatCompletion(ctx context.Context, msgs []goopenai.ChatCompletionMessage, model string) (string, error) {
+	f.mu.Lock()
+	f.calls++
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+
+	var n, total int
+	if _, err := fmt.Sscanf(msgs[0].Content, "The following messages are part %d of %d", &n, &total); err != nil {
+		// a merge request or a request that fit at once
+		return "merged:\n" + msgs[len(msgs)-1].Content, nil
+	}
+	if f.delay != nil {
+		select {
+		case <-time.After(f.delay(n, total)):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if f.fail != nil {
+		if err := f.fail(n); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("summary %d", n), nil
+}
+
+// message returns a user message of about tokens tokens.
+func message(tokens int) goopenai.ChatCompletionMessage {
+	return goopenai.ChatCompletionMessage{Role: goopenai.ChatMessageRoleUser, Content: strings.Repeat("abcd", tokens)}
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name   string
+		sizes  []int // tokens of each message
+		budget int
+		want   []int // messages per chunk
+	}{
+		{"empty", nil, 10, nil},
+		{"fits", []int{3, 3, 4}, 10, []int{3}},
+		{"exact boundary", []int{5, 5, 5, 5}, 10, []int{2, 2}},
+		{"one over", []int{5, 6, 4}, 10, []int{1, 2}},
+		{"each alone", []int{8, 8, 8}, 10, []int{1, 1, 1}},
+		{"oversized message is split", []int{2, 25, 2}, 10, []int{1, 1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := make([]goopenai.ChatCompletionMessage, len(tt.sizes))
+			for i, n := range tt.sizes {
+				msgs[i] = message(n)
+			}
+			chunks := Chunk(msgs, tt.budget)
+			got := make([]int, len(chunks))
+			for i, c := range chunks {
+				got[i] = len(c)
+				used := 0
+				for _, m := range c {
+					used += tokens(m)
+				}
+				if used > tt.budget {
+					t.Errorf("chunk %d has %d tokens, over the budget of %d", i, used, tt.budget)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("chunk sizes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeWorkerLimit(t *testing.T) {
+	tests := []struct {
+		workers, parts int
+	}{
+		{1, 5},
+		{2, 6},
+		{4, 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d workers %d parts", tt.workers, tt.parts), func(t *testing.T) {
+			f := &fakeCompleter{delay: func(int, int) time.Duration { return 20 * time.Millisecond }}
+			msgs := make([]goopenai.ChatCompletionMessage, tt.parts)
+			for i := range msgs {
+				msgs[i] = message(100)
+			}
+			// room for one message per request next to the prompts
+			opts := Options{MaxTokens: 200, Workers: tt.workers}
+			if _, err := Summarize(context.Background(), f, "system", msgs, opts); err != nil {
+				t.Fatal(err)
+			}
+			if want := min(tt.workers, tt.parts); f.peak != want {
+				t.Errorf("peak concurrency = %d, want %d", f.peak, want)
+			}
+			if f.calls != tt.parts+1 {
+				t.Errorf("calls = %d, want %d map requests and one merge", f.calls, tt.parts)
+			}
+		})
+	}
+}
+
+func TestSummarizeMergeOrder(t *testing.T) {
+	// later parts finish first
+	f := &fakeCompleter{delay: func(n, total int) time.Duration { return time.Duration(total-n) * 10 * time.Millisecond }}
+	msgs := []goopenai.ChatCompletionMessage{message(100), message(100), message(100), message(100)}
+	var progress []int
+	opts := Options{MaxTokens: 200, Workers: 4, Progress: func(done, total int) { progress = append(progress, done) }}
+	out, err := Summarize(context.Background(), f, "system", msgs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := -1
+	for n := 1; n <= 4; n++ {
+		i := strings.Index(out, fmt.Sprintf("## Part %d\n\nsummary %d\n", n, n))
+		if i < 0 || i < last {
+			t.Fatalf("part %d missing or out of order in merge input:\n%s", n, out)
+		}
+		last = i
+	}
+	if fmt.Sprint(progress) != "[1 2 3 4]" {
+		t.Errorf("progress = %v, want [1 2 3 4]", progress)
+	}
+}
+
+func TestSummarizeError(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name    string
+		failing int
+	}{
+		{"first part", 1},
+		{"middle part", 3},
+		{"last part", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeCompleter{
+				// the other parts are slow, so the failure has to cancel them
+				delay: func(n, total int) time.Duration {
+					if n == tt.failing {
+						return 0
+					}
+					return time.Second
+				},
+				fail: func(n int) error {
+					if n == tt.failing {
+						return boom
+					}
+					return nil
+				},
+			}
+			msgs := make([]goopenai.ChatCompletionMessage, 5)
+			for i := range msgs {
+				msgs[i] = message(100)
+			}
+			start := time.Now()
+			_, err := Summarize(context.Background(), f, "system", msgs, Options{MaxTokens: 200, Workers: 5})
+			if !errors.Is(err, boom) {
+				t.Fatalf("err = %v, want it to wrap %v", err, boom)
+			}
+			if want := fmt.Sprintf("part %d of 5", tt.failing); !strings.Contains(err.Error(), want) {
+				t.Errorf("err = %q, want it to name %q", err, want)
+			}
+			if time.Since(start) > 500*time.Millisecond {
+				t.Errorf("the other requests were not cancelled")
+			}
+		})
+	}
+}
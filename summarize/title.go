@@ -0,0 +1,97 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	goopenai "github.com/sashabaranov/go-openai"
+)
+
+// titlePrompt asks for a title and tags for a note.
+const titlePrompt = "Suggest a short, descriptive title (at most 8 words) and 1-5 lowercase tags for the note below. " +
+	"Prefer reusing these existing tags where they fit: %s."
+
+// titleSchema is the JSON schema of the title reply.
+var titleSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "title": {"type": "string"},
+    "tags": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["title", "tags"],
+  "additionalProperties": false
+}`)
+
+// TitleAndTags asks c for a human title and tags for a note body. vocabulary
+// lists the tags already in use so the model can reuse them.
+func TitleAndTags(ctx context.Context, c JSONCompleter, body string, vocabulary []string, model string) (string, []string, error) {
+	existing := "none yet"
+	if len(vocabulary) > 0 {
+		existing = strings.Join(vocabulary, ", ")
+	}
+	msgs := []goopenai.ChatCompletionMessage{
+		{Role: goopenai.ChatMessageRoleSystem, Content: fmt.Sprintf(titlePrompt, existing)},
+		{Role: goopenai.ChatMessageRoleUser, Content: body},
+	}
+	schema := &goopenai.ChatCompletionResponseFormatJSONSchema{Name: "note_title", Schema: titleSchema, Strict: true}
+	reply, err := c.ChatCompletionJSON(ctx, msgs, model, schema)
+	if err != nil {
+		return "", nil, err
+	}
+	var out struct {
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(reply), &out); err != nil {
+		return "", nil, fmt.Errorf("malformed title reply: %w", err)
+	}
+	title := strings.TrimSpace(out.Title)
+	if title == "" {
+		return "", nil, fmt.Errorf("empty title in reply")
+	}
+	return title, out.Tags, nil
+}
+
+// sessionTitlePrompt asks for a title and description of a conversation.
+const sessionTitlePrompt = "Suggest a short title (at most 6 words) and a one-sentence description for the conversation below."
+
+// sessionTitleSchema is the JSON schema of the session title reply.
+var sessionTitleSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "title": {"type": "string"},
+    "description": {"type": "string"}
+  },
+  "required": ["title", "description"],
+  "additionalProperties": false
+}`)
+
+// sessionTitleTokens caps how much of the conversation is sent for a title.
+const sessionTitleTokens = 4000
+
+// SessionTitle asks c for a title and description of the conversation msgs.
+func SessionTitle(ctx context.Context, c JSONCompleter, msgs []goopenai.ChatCompletionMessage, model string) (string, string, error) {
+	chunks := Chunk(msgs, sessionTitleTokens)
+	if len(chunks) > 0 {
+		msgs = chunks[0]
+	}
+	schema := &goopenai.ChatCompletionResponseFormatJSONSchema{Name: "session_title", Schema: sessionTitleSchema, Strict: true}
+	reply, err := c.ChatCompletionJSON(ctx, withSystem(sessionTitlePrompt, msgs), model, schema)
+	if err != nil {
+		return "", "", err
+	}
+	var out struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(reply), &out); err != nil {
+		return "", "", fmt.Errorf("malformed title reply: %w", err)
+	}
+	title := strings.TrimSpace(out.Title)
+	if title == "" {
+		return "", "", fmt.Errorf("empty title in reply")
+	}
+	return title, strings.TrimSpace(out.Description), nil
+}
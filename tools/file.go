@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergey-suslov/ai-notes/attach"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// maxFileBytes is how much of a file read_file returns.
+const maxFileBytes = 64 << 10
+
+// readFile implements the read_file tool. Only files under the allowed
+// directories can be read, whatever symlinks the path goes through.
+func (b *Toolbox) readFile(_ *store.Session, args json.RawMessage) (string, error) {
+	var a struct {
+		Path string `json:"path"`
+	}
+	if err := decode(args, &a); err != nil {
+		return "", err
+	}
+	if len(b.readDirs) == 0 {
+		return "", errors.New("no directories are allowed to be read")
+	}
+	path := attach.ExpandHome(a.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(b.readDirs[0], path)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", a.Path, err)
+	}
+	if !b.allowed(real) {
+		return "", fmt.Errorf("%s is outside the allowed directories %s", a.Path, strings.Join(b.readDirs, ", "))
+	}
+	f, err := os.Open(real)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", a.Path, err)
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		return "", fmt.Errorf("%s is not a file", a.Path)
+	}
+	// one byte more tells whether the file was cut
+	data, err := io.ReadAll(io.LimitReader(f, maxFileBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", a.Path, err)
+	}
+	truncated := len(data) > maxFileBytes
+	if truncated {
+		data = data[:maxFileBytes]
+		// a character cut at the limit does not make the file binary
+		for i := 1; i < utf8.UTFMax && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", fmt.Errorf("%s is not a text file", a.Path)
+	}
+	text := string(data)
+	if truncated {
+		text += fmt.Sprintf("\n[truncated after %d bytes]", maxFileBytes)
+	}
+	return text, nil
+}
+
+// allowed reports whether path, absolute and free of symlinks, is inside one
+// of the allowed directories.
+func (b *Toolbox) allowed(path string) bool {
+	for _, dir := range b.readDirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
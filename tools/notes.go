@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// defaultLimit is how many results the search tools return by default.
+const defaultLimit = 20
+
+// searchNotes implements the search_notes tool.
+func (b *Toolbox) searchNotes(_ *store.Session, args json.RawMessage) (string, error) {
+	var a struct {
+		Query    string `json:"query"`
+		Tag      string `json:"tag"`
+		Notebook string `json:"notebook"`
+		Limit    int    `json:"limit"`
+	}
+	if err := decode(args, &a); err != nil {
+		return "", err
+	}
+	notes, err := store.LoadAllNotes(b.vaults)
+	if err != nil {
+		return "", err
+	}
+	if a.Notebook != "" {
+		notes = store.FilterNotebook(notes, store.NormalizeNotebook(a.Notebook))
+	}
+	tag := strings.TrimPrefix(strings.ToLower(a.Tag), "#")
+	var lines []string
+	for _, n := range notes {
+		if (tag == "" || n.HasTag(tag)) && n.Matches(a.Query) {
+			lines = append(lines, fmt.Sprintf("- %s: %s (%s)", n.ID, n.Title, describe(n)))
+		}
+	}
+	if len(lines) == 0 {
+		return "No matching notes.", nil
+	}
+	return list(lines, a.Limit), nil
+}
+
+// readNote implements the read_note tool.
+func (b *Toolbox) readNote(_ *store.Session, args json.RawMessage) (string, error) {
+	var a struct {
+		ID string `json:"id"`
+	}
+	if err := decode(args, &a); err != nil {
+		return "", err
+	}
+	notes, err := store.LoadAllNotes(b.vaults)
+	if err != nil {
+		return "", err
+	}
+	for _, n := range notes {
+		if n.ID == a.ID {
+			return fmt.Sprintf("# %s\n\n_%s_\n\n%s", n.Title, describe(n), n.Markdown()), nil
+		}
+	}
+	return "", fmt.Errorf("note %q not found", a.ID)
+}
+
+// saveNote implements the save_note tool. The note is linked to sess and
+// filed in its notebook unless another is given.
+func (b *Toolbox) saveNote(sess *store.Session, args json.RawMessage) (string, error) {
+	var a struct {
+		Title    string   `json:"title"`
+		Body     string   `json:"body"`
+		Tags     []string `json:"tags"`
+		Notebook string   `json:"notebook"`
+	}
+	if err := decode(args, &a); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(a.Title) == "" {
+		return "", fmt.Errorf("title is empty")
+	}
+	notes, err := store.LoadNotes()
+	if err != nil {
+		return "", err
+	}
+	n := store.NewNote(sess.ID, a.Body)
+	n.Title = a.Title
+	n.Notebook = sess.Notebook
+	if a.Notebook != "" {
+		n.Notebook = store.NormalizeNotebook(a.Notebook)
+	}
+	n.Tags = store.NormalizeTags(a.Tags, store.TagVocabulary(notes))
+	path, err := n.Save()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Saved note %s: %s to %s", n.ID, n.Title, path), nil
+}
+
+// listSessions implements the list_sessions tool.
+func (b *Toolbox) listSessions(_ *store.Session, args json.RawMessage) (string, error) {
+	var a struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := decode(args, &a); err != nil {
+		return "", err
+	}
+	sessions, err := store.LoadSessions()
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, s := range sessions {
+		if !s.Matches(a.Query) {
+			continue
+		}
+		line := fmt.Sprintf("- %s: %s (%s, %d messages)", s.ID, s.DisplayTitle(), s.CreatedAt.Format("2006-01-02"), len(s.Chat))
+		if s.Description != "" {
+			line += " " + s.Description
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "No matching sessions.", nil
+	}
+	return list(lines, a.Limit), nil
+}
+
+// list joins the first limit lines, defaultLimit if limit is not positive,
+// noting how many were left out.
+func list(lines []string, limit int) string {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if len(lines) <= limit {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:limit], "\n") + fmt.Sprintf("\n… and %d more; narrow the search to see them.", len(lines)-limit)
+}
+
+// describe summarizes a note's metadata on one line.
+func describe(n *store.Note) string {
+	parts := []string{n.CreatedAt.Format("2006-01-02")}
+	if n.Notebook != "" {
+		parts = append(parts, "notebook "+n.Notebook)
+	}
+	if len(n.Tags) > 0 {
+		parts = append(parts, "#"+strings.Join(n.Tags, " #"))
+	}
+	if n.External != "" {
+		parts = append(parts, "read-only")
+	}
+	return strings.Join(parts, ", ")
+}
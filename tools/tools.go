@@ -0,0 +1,194 @@
+// Package tools implements the functions the chat assistant may call to
+// use the notes store: searching and reading notes and sessions, saving
+// notes and reading files from allowed directories.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	goopenai "github.com/sashabaranov/go-openai"
+
+	"github.com/sergey-suslov/ai-notes/attach"
+	"github.com/sergey-suslov/ai-notes/config"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// Tool is a function offered to the model.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments.
+	Parameters map[string]any
+	// SideEffect marks tools that change the store. They run only once the
+	// user confirms the call.
+	SideEffect bool
+
+	run func(b *Toolbox, sess *store.Session, args json.RawMessage) (string, error)
+}
+
+// Toolbox runs tools against the notes store.
+type Toolbox struct {
+	// vaults are indexed markdown folders searched along with the notes
+	vaults []string
+	// readDirs are the absolute, symlink-free directories read_file may read
+	readDirs []string
+}
+
+// New returns a Toolbox searching vaults along with the notes, whose
+// read_file tool may read files under readDirs.
+func New(vaults, readDirs []string) (*Toolbox, error) {
+	b := &Toolbox{vaults: vaults}
+	for _, dir := range readDirs {
+		abs, err := filepath.Abs(attach.ExpandHome(dir))
+		if err != nil {
+			return nil, fmt.Errorf("read_file directory %s: %w", dir, err)
+		}
+		// resolved so a symlink cannot lead a path out of the sandbox
+		real, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("read_file directory %s: %w", dir, err)
+		}
+		b.readDirs = append(b.readDirs, real)
+	}
+	return b, nil
+}
+
+// FromConfig returns the Toolbox configured by cfg, or nil unless tools are
+// enabled. read_file may only read the configured directories, so that a
+// model steered by what it reads cannot reach files the user did not share.
+func FromConfig(cfg *config.Config) (*Toolbox, error) {
+	if !cfg.Tools.Enabled {
+		return nil, nil
+	}
+	return New(cfg.Vaults, cfg.Tools.ReadDirs)
+}
+
+// Definitions returns the tools in the form the provider expects. read_file
+// is left out if no directory may be read.
+func (b *Toolbox) Definitions() []goopenai.Tool {
+	var defs []goopenai.Tool
+	for _, t := range tools {
+		if t.Name == "read_file" {
+			if len(b.readDirs) == 0 {
+				continue
+			}
+			t.Description += " Allowed directories: " + strings.Join(b.readDirs, ", ") + "."
+		}
+		defs = append(defs, goopenai.Tool{
+			Type: goopenai.ToolTypeFunction,
+			Function: &goopenai.FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return defs
+}
+
+// SideEffect reports whether the named tool changes the store and so needs
+// confirmation. Unknown tools are treated as changing it.
+func (b *Toolbox) SideEffect(name string) bool {
+	t, ok := lookup(name)
+	return !ok || t.SideEffect
+}
+
+// Run runs call on behalf of sess and returns its result for the model.
+func (b *Toolbox) Run(sess *store.Session, call store.ToolCall) (string, error) {
+	t, ok := lookup(call.Name)
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Name)
+	}
+	args := json.RawMessage(call.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	return t.run(b, sess, args)
+}
+
+// lookup returns the tool with the given name.
+func lookup(name string) (Tool, bool) {
+	for _, t := range tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// decode parses the arguments of a call into v.
+func decode(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// schema builds an object schema from property schemas and required names.
+func schema(props map[string]any, required ...string) map[string]any {
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// stringProp is the schema of a string argument.
+func stringProp(desc string) map[string]any {
+	return map[string]any{"type": "string", "description": desc}
+}
+
+// tools are the tools offered to the model.
+var tools = []Tool{
+	{
+		Name:        "search_notes",
+		Description: "Search the user's notes. Returns the ID, title and metadata of notes containing every word of the query, newest first.",
+		Parameters: schema(map[string]any{
+			"query":    stringProp("Words to search for in titles, content and tags. Empty lists all notes."),
+			"tag":      stringProp("Only notes with this tag."),
+			"notebook": stringProp("Only notes in this notebook, e.g. \"work/projects\", or its sub-notebooks."),
+			"limit":    map[string]any{"type": "integer", "description": "Maximum number of results, 20 by default."},
+		}),
+		run: (*Toolbox).searchNotes,
+	},
+	{
+		Name:        "read_note",
+		Description: "Read a note by ID, as markdown.",
+		Parameters:  schema(map[string]any{"id": stringProp("The note ID, as returned by search_notes.")}, "id"),
+		run:         (*Toolbox).readNote,
+	},
+	{
+		Name:        "save_note",
+		Description: "Save a new note for the user. Link other notes by title with [[Title]]. The user confirms before it is saved.",
+		Parameters: schema(map[string]any{
+			"title": stringProp("The note title."),
+			"body":  stringProp("The note content, as markdown."),
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Tags, e.g. [\"golang\", \"deploy\"].",
+			},
+			"notebook": stringProp("Notebook to file the note in, e.g. \"work/projects\". Defaults to the session's notebook."),
+		}, "title", "body"),
+		SideEffect: true,
+		run:        (*Toolbox).saveNote,
+	},
+	{
+		Name:        "list_sessions",
+		Description: "List the user's past chat sessions, newest first, optionally only those containing every word of the query.",
+		Parameters: schema(map[string]any{
+			"query": stringProp("Words to search for in session titles, descriptions and messages."),
+			"limit": map[string]any{"type": "integer", "description": "Maximum number of results, 20 by default."},
+		}),
+		run: (*Toolbox).listSessions,
+	},
+	{
+		Name:        "read_file",
+		Description: "Read a text file from the directories the user allowed. Relative paths are resolved against the first of them.",
+		Parameters:  schema(map[string]any{"path": stringProp("Path of the file.")}, "path"),
+		run:         (*Toolbox).readFile,
+	},
+}
@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// actionRef points at one action item inside a note.
+type actionRef struct {
+	note *store.Note
+	idx  int
+}
+
+// actionsModel lists the action items of all notes and lets the user check them off.
+type actionsModel struct {
+	items  []actionRef
+	cursor int
+	err    error
+}
+
+// newActionsModel collects the action items of notes, open items first.
+func newActionsModel(notes []*store.Note) *actionsModel {
+	var open, done []actionRef
+	for _, n := range notes {
+		for i, it := range n.ActionItems {
+			if it.Done {
+				done = append(done, actionRef{note: n, idx: i})
+			} else {
+				open = append(open, actionRef{note: n, idx: i})
+			}
+		}
+	}
+	return &actionsModel{items: append(open, done...)}
+}
+
+// Init is required by Bubble Tea; no initial command.
+func (m *actionsModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles navigation and toggling items.
+func (m *actionsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyUp:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case tea.KeyDown:
+			if m.cursor < len(m.items)-1 {
+				m.cursor++
+			}
+		case tea.KeySpace, tea.KeyEnter:
+			m.toggle()
+		case tea.KeyRunes:
+			if msg.String() == "x" {
+				m.toggle()
+			}
+		}
+	}
+	return m, nil
+}
+
+// toggle flips the highlighted item and saves its note.
+func (m *actionsModel) toggle() {
+	if len(m.items) == 0 {
+		return
+	}
+	ref := m.items[m.cursor]
+	item := &ref.note.ActionItems[ref.idx]
+	item.Done = !item.Done
+	if _, err := ref.note.Save(); err != nil {
+		item.Done = !item.Done
+		m.err = err
+		return
+	}
+	m.err = nil
+}
+
+// View renders the action items with their notes.
+func (m *actionsModel) View() string {
+	var b strings.Builder
+	b.WriteString("Action items (↑/↓, space to check off, esc to go back):\n\n")
+	if len(m.items) == 0 {
+		b.WriteString("  No action items. Generate structured notes to collect them.\n")
+	}
+	for i, ref := range m.items {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		item := ref.note.ActionItems[ref.idx]
+		b.WriteString(fmt.Sprintf("%s %s — %s\n", cursor, strings.TrimPrefix(item.String(), "- "), ref.note.Title))
+	}
+	if m.err != nil {
+		b.WriteString("\nError saving note: " + m.err.Error() + "\n")
+	}
+	return b.String()
+}
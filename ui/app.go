@@ -3,10 +3,14 @@ package ui
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sergey-suslov/ai-notes/config"
 	openaiclient "github.com/sergey-suslov/ai-notes/openai"
+	"github.com/sergey-suslov/ai-notes/redact"
 	"github.com/sergey-suslov/ai-notes/store"
+	"github.com/sergey-suslov/ai-notes/tools"
 )
 
 // screen identifiers
@@ -15,6 +19,11 @@ const (
 	screenChat
 	screenNotes
 	screenView
+	screenTemplates
+	screenActions
+	screenTags
+	screenTrash
+	screenHistory
 )
 
 // AppModel is the top-level Bubble Tea model managing multiple screens.
@@ -28,8 +37,26 @@ type AppModel struct {
 	chat    model
 
 	// notes browser and note viewer
-	notes *notesModel
-	view  *viewModel
+	notes   *notesModel
+	view    *viewModel
+	history *historyModel
+
+	// note template picker shown by Ctrl+N
+	templates *templatesModel
+
+	// cross-note action items and tag browser, opened from the notes browser
+	actions *actionsModel
+	tags    *tagsModel
+
+	// trash screen, opened from the session picker
+	trash *trashModel
+
+	// vaults are indexed folders of markdown notes, from the config
+	vaults []string
+	// rules select what is redacted from requests, nil if redaction is off
+	rules []redact.Rule
+	// toolbox runs the tools offered to the chat assistant, nil if they are off
+	toolbox *tools.Toolbox
 
 	screen int
 
@@ -38,16 +65,52 @@ type AppModel struct {
 	exitWithoutSaving bool
 }
 
-// NewAppModel creates the application model with loaded sessions.
-func NewAppModel(client *openaiclient.Client, sessions []*store.Session) *AppModel {
+// NewAppModel creates the application model with loaded sessions and notes.
+func NewAppModel(client *openaiclient.Client, sessions []*store.Session, notes []*store.Note) *AppModel {
+	selection := newSelectionModel(sessions)
+	selection.setNotes(notes)
 	return &AppModel{
 		client:    client,
 		sessions:  sessions,
-		selection: newSelectionModel(sessions),
+		selection: selection,
 		screen:    screenSelect,
 	}
 }
 
+// openSource switches the chat to the session note was generated from,
+// scrolled to its source messages. The active session is saved first.
+func (m *AppModel) openSource(note *store.Note) error {
+	if note.SessionID == "" {
+		return fmt.Errorf("this note has no source session")
+	}
+	sess := m.session
+	if sess == nil || sess.ID != note.SessionID {
+		sess = nil
+		for _, s := range m.sessions {
+			if s.ID == note.SessionID {
+				sess = s
+				break
+			}
+		}
+		if sess == nil {
+			return fmt.Errorf("source session %s not found; it may have been deleted", note.SessionID)
+		}
+		if m.session != nil {
+			if err := m.session.Save(); err != nil {
+				return fmt.Errorf("saving session: %w", err)
+			}
+		}
+	}
+	if sess != m.session {
+		m.session = sess
+		m.chat = NewModel(m.client, sess, m.rules, m.toolbox, m.windowSize)
+	}
+	m.chat.showSource(note)
+	m.screen = screenChat
+	m.notes, m.view = nil, nil
+	return nil
+}
+
 // Init does nothing; focus is managed by sub-models.
 func (m *AppModel) Init() tea.Cmd {
 	return nil
@@ -61,35 +124,68 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.WindowSizeMsg:
 			m.windowSize = msg
 		}
-		// delegate to selectionModel
+		// delegate to selectionModel; Esc may only cancel a rename or filter
+		capturesEsc := m.selection.capturesEsc()
 		newSel, cmd := m.selection.Update(msg)
 		m.selection = newSel.(*selectionModel)
+		if sess := m.selection.openNotes; sess != nil {
+			m.selection.openNotes = nil
+			notes, err := store.LoadAllNotes(m.vaults)
+			if err != nil {
+				m.selection.err = err
+				return m, nil
+			}
+			m.notes = newNotesModel(m.client, m.rules, notes)
+			m.notes.setSession(sess)
+			m.screen = screenNotes
+			return m, nil
+		}
+		if m.selection.openTrash {
+			m.selection.openTrash = false
+			items, err := store.LoadTrash()
+			if err != nil {
+				m.selection.err = err
+				return m, nil
+			}
+			m.trash = newTrashModel(items)
+			m.screen = screenTrash
+			return m, nil
+		}
 		// if a session was picked, move to chat
 		if m.selection.selectedSession != nil {
 			m.session = m.selection.selectedSession
-			m.chat = NewModel(m.client, m.session, m.windowSize)
+			m.chat = NewModel(m.client, m.session, m.rules, m.toolbox, m.windowSize)
 			m.screen = screenChat
 			return m, m.chat.Init()
 		}
 		// allow quitting
-		if k, ok := msg.(tea.KeyMsg); ok && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
+		if k, ok := msg.(tea.KeyMsg); ok && !capturesEsc && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
 			return m, tea.Quit
 		}
 		return m, cmd
 
 	case screenChat:
-		// global keybindings
-		if k, ok := msg.(tea.KeyMsg); ok {
+		// global keybindings, unless a prompt of the chat is to be answered
+		if k, ok := msg.(tea.KeyMsg); ok && !m.chat.prompting() {
 			switch k.Type {
 			case tea.KeyCtrlL:
-				notes, err := store.LoadNotes()
+				notes, err := store.LoadAllNotes(m.vaults)
 				if err != nil {
-					m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: "Error loading notes: " + err.Error()})
+					m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Error loading notes: " + err.Error()})
 					return m, nil
 				}
-				m.notes = newNotesModel(notes)
+				m.notes = newNotesModel(m.client, m.rules, notes)
 				m.screen = screenNotes
 				return m, nil
+			case tea.KeyCtrlN:
+				templates, err := store.LoadTemplates()
+				if err != nil {
+					m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Error loading note templates: " + err.Error()})
+					return m, nil
+				}
+				m.templates = newTemplatesModel(templates)
+				m.screen = screenTemplates
+				return m, nil
 			case tea.KeyCtrlD:
 				m.exitWithoutSaving = true
 				return m, tea.Quit
@@ -102,28 +198,122 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 
 	case screenNotes:
-		// browse notes
+		// browse notes; Esc while editing tags or confirming only cancels that
+		capturesEsc := m.notes.capturesEsc()
 		newNotes, cmd := m.notes.Update(msg)
 		m.notes = newNotes.(*notesModel)
+		switch m.notes.action {
+		case "actions":
+			m.notes.action = ""
+			m.actions = newActionsModel(m.notes.notes)
+			m.screen = screenActions
+			return m, nil
+		case "tags":
+			m.notes.action = ""
+			m.tags = newTagsModel(m.notes.all)
+			m.screen = screenTags
+			return m, nil
+		}
+		// inject the chosen notes into the chat
+		if m.notes.action == "inject" {
+			notes := m.notes.inject
+			m.notes.inject, m.notes.action = nil, ""
+			if m.session == nil {
+				// opened from the session picker
+				m.notes.err = fmt.Errorf("open a session to inject notes into it")
+				return m, nil
+			}
+			titles := make([]string, len(notes))
+			for i, n := range notes {
+				content := n.Markdown()
+				if len(notes) > 1 {
+					content = "# " + n.Title + "\n\n" + content
+				}
+				m.session.Chat = append(m.session.Chat, store.Message{Role: "system", Content: content})
+				titles[i] = n.Title
+			}
+			m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: fmt.Sprintf("Injected notes: %s", strings.Join(titles, ", "))})
+			m.screen = screenChat
+			m.notes = nil
+			return m, nil
+		}
 		// if a note was selected
-		if sel := m.notes.selected; sel != nil {
-			switch m.notes.action {
-			case "inject":
-				m.session.Chat = append(m.session.Chat, store.Message{Role: "system", Content: sel.Body})
-				m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: fmt.Sprintf("Injected notes: %s", sel.Title)})
-				m.screen = screenChat
+		if sel := m.notes.selected; sel != nil && m.notes.action == "view" {
+			vm := newViewModel(sel, m.notes.all, m.windowSize)
+			m.view = &vm
+			m.screen = screenView
+			return m, nil
+		}
+		// exit notes view, back to the picker if no session is open
+		if k, ok := msg.(tea.KeyMsg); ok && !capturesEsc && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
+			if m.session == nil {
+				m.selection.setNotes(m.notes.all)
+				m.screen = screenSelect
 				m.notes = nil
 				return m, nil
-			case "view":
-				vm := newViewModel(sel, m.windowSize)
-				m.view = &vm
-				m.screen = screenView
-				return m, nil
 			}
+			m.screen = screenChat
+			return m, nil
+		}
+		return m, cmd
+
+	case screenTemplates:
+		newTemplates, cmd := m.templates.Update(msg)
+		m.templates = newTemplates.(*templatesModel)
+		if req := m.templates.selected; req != nil {
+			m.screen = screenChat
+			var notesCmd tea.Cmd
+			m.chat, notesCmd = m.chat.generateNotes(*req)
+			m.templates = nil
+			return m, notesCmd
 		}
-		// exit notes view
 		if k, ok := msg.(tea.KeyMsg); ok && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
 			m.screen = screenChat
+			m.templates = nil
+			return m, nil
+		}
+		return m, cmd
+
+	case screenActions:
+		newActions, cmd := m.actions.Update(msg)
+		m.actions = newActions.(*actionsModel)
+		if k, ok := msg.(tea.KeyMsg); ok && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
+			m.screen = screenNotes
+			m.actions = nil
+			return m, nil
+		}
+		return m, cmd
+
+	case screenTrash:
+		newTrash, cmd := m.trash.Update(msg)
+		m.trash = newTrash.(*trashModel)
+		if k, ok := msg.(tea.KeyMsg); ok && !m.trash.purging && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
+			// pick up restored sessions
+			sessions, err := store.LoadSessions()
+			if err != nil {
+				m.selection.err = err
+			} else {
+				m.sessions = sessions
+				m.selection.setSessions(sessions)
+			}
+			m.screen = screenSelect
+			m.trash = nil
+			return m, nil
+		}
+		return m, cmd
+
+	case screenTags:
+		newTags, cmd := m.tags.Update(msg)
+		m.tags = newTags.(*tagsModel)
+		if tag := m.tags.selected; tag != nil {
+			m.notes.setFilter(*tag)
+			m.screen = screenNotes
+			m.tags = nil
+			return m, nil
+		}
+		if k, ok := msg.(tea.KeyMsg); ok && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
+			m.screen = screenNotes
+			m.tags = nil
 			return m, nil
 		}
 		return m, cmd
@@ -141,6 +331,31 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.notes.action = ""
 			return m, nil
 		}
+		if _, ok := msg.(viewSessionMsg); ok {
+			if err := m.openSource(m.view.note); err != nil {
+				m.view.err = err
+			}
+			return m, nil
+		}
+		if _, ok := msg.(viewHistoryMsg); ok {
+			m.history = newHistoryModel(m.view.note, m.windowSize)
+			m.screen = screenHistory
+			return m, nil
+		}
+		return m, cmd
+
+	case screenHistory:
+		// revisions of the viewed note; Esc in a diff or prompt only closes it
+		capturesEsc := m.history.capturesEsc()
+		newHistory, cmd := m.history.Update(msg)
+		m.history = newHistory.(*historyModel)
+		if k, ok := msg.(tea.KeyMsg); ok && !capturesEsc && (k.Type == tea.KeyCtrlC || k.Type == tea.KeyEsc) {
+			// the note may have been restored
+			m.view.open(m.history.note)
+			m.screen = screenView
+			m.history = nil
+			return m, nil
+		}
 		return m, cmd
 	}
 	return m, nil
@@ -157,6 +372,16 @@ func (m *AppModel) View() string {
 		return m.notes.View()
 	case screenView:
 		return m.view.View()
+	case screenTemplates:
+		return m.templates.View()
+	case screenActions:
+		return m.actions.View()
+	case screenTags:
+		return m.tags.View()
+	case screenTrash:
+		return m.trash.View()
+	case screenHistory:
+		return m.history.View()
 	default:
 		return ""
 	}
@@ -164,15 +389,37 @@ func (m *AppModel) View() string {
 
 // Run initializes everything and starts the Bubble Tea program.
 func Run() error {
-	client, err := openaiclient.NewClient()
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	client, err := openaiclient.NewClientFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	rules, err := redact.FromConfig(cfg.Redact)
 	if err != nil {
-		return fmt.Errorf("creating OpenAI client: %w", err)
+		return fmt.Errorf("loading config: %w", err)
+	}
+	toolbox, err := tools.FromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if _, err := store.PurgeTrash(cfg.TrashRetention()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to purge trash: %v\n", err)
 	}
 	sessions, err := store.LoadSessions()
 	if err != nil {
 		return fmt.Errorf("loading sessions: %w", err)
 	}
-	app := NewAppModel(client, sessions)
+	notes, err := store.LoadAllNotes(cfg.Vaults)
+	if err != nil {
+		return fmt.Errorf("loading notes: %w", err)
+	}
+	app := NewAppModel(client, sessions, notes)
+	app.vaults = cfg.Vaults
+	app.rules = rules
+	app.toolbox = toolbox
 	p := tea.NewProgram(app, tea.WithAltScreen())
 	_, err = p.Run()
 	// save the session if one was active
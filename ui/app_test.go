@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// chatApp returns an AppModel showing a chat in a new session.
+func chatApp(t *testing.T) *AppModel {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	m := NewAppModel(nil, nil, nil)
+	m.session = store.NewSession()
+	m.chat = NewModel(nil, m.session, nil, nil, tea.WindowSizeMsg{Width: 80, Height: 24})
+	m.screen = screenChat
+	return m
+}
+
+// quits reports whether cmd, or a command it batches, quits the program.
+func quits(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	switch msg := cmd().(type) {
+	case tea.QuitMsg:
+		return true
+	case tea.BatchMsg:
+		for _, c := range msg {
+			if quits(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestChatPromptKeys(t *testing.T) {
+	confirm := func(m *AppModel) {
+		m.chat.queue = []store.ToolCall{{ID: "call_1", Name: "save_note", Arguments: `{"title":"t","body":"b"}`}}
+		m.chat.confirming = true
+	}
+	retry := func(m *AppModel) {
+		m.chat.retry = func(m model) (model, tea.Cmd) {
+			t.Error("the blocked request was resent")
+			return m, nil
+		}
+	}
+	tests := []struct {
+		name     string
+		prompt   func(m *AppModel)
+		key      tea.KeyType
+		wantQuit bool
+		// wantStatus is the last chat line expected after the key
+		wantStatus string
+	}{
+		{"esc at tool confirmation", confirm, tea.KeyEsc, false, "Tool calls cancelled."},
+		{"ctrl+c at tool confirmation", confirm, tea.KeyCtrlC, true, ""},
+		{"esc at budget prompt", retry, tea.KeyEsc, false, ""},
+		{"ctrl+c at budget prompt", retry, tea.KeyCtrlC, true, ""},
+		{"esc without prompt", func(*AppModel) {}, tea.KeyEsc, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := chatApp(t)
+			tt.prompt(m)
+			_, cmd := m.Update(tea.KeyMsg{Type: tt.key})
+			if got := quits(cmd); got != tt.wantQuit {
+				t.Fatalf("quit = %v, want %v", got, tt.wantQuit)
+			}
+			if tt.wantQuit {
+				return
+			}
+			if m.screen != screenChat {
+				t.Errorf("screen = %d, want the chat", m.screen)
+			}
+			if m.chat.prompting() {
+				t.Error("the prompt is still open")
+			}
+			if len(m.chat.queue) != 0 {
+				t.Errorf("queue = %v, want the tool calls dropped", m.chat.queue)
+			}
+			if tt.wantStatus != "" {
+				chat := m.session.Chat
+				if len(chat) == 0 || chat[len(chat)-1].Role != store.RoleStatus || chat[len(chat)-1].Content != tt.wantStatus {
+					t.Errorf("chat = %+v, want it to end with %q", chat, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestLivingNoteReplacedOnceDeleted(t *testing.T) {
+	m := chatApp(t)
+	living := store.NewNote(m.session.ID, "old notes")
+	if _, err := living.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := living.Trash(); err != nil {
+		t.Fatal(err)
+	}
+	m.session.LivingNoteID = living.ID
+	m.session.Chat = append(m.session.Chat,
+		store.Message{Role: "user", Content: "first"},
+		store.Message{Role: "assistant", Content: "reply"},
+	)
+	// everything was noted in the deleted note
+	m.session.NotedUpTo = len(m.session.Chat)
+	chat, cmd := m.chat.generateNotes(noteRequest{tpl: &store.Template{Name: "summary"}, mode: noteLiving})
+	if cmd == nil {
+		t.Fatal("no notes are generated")
+	}
+	if chat.session.LivingNoteID != "" {
+		t.Errorf("LivingNoteID = %q, want it cleared", chat.session.LivingNoteID)
+	}
+	last := chat.session.Chat[len(chat.session.Chat)-1]
+	if last.Content != "Generating summary notes..." {
+		t.Errorf("last line = %q, want notes generated for the whole session", last.Content)
+	}
+}
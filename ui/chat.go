@@ -2,8 +2,11 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/glamour"
 
@@ -14,13 +17,20 @@ import (
 
 	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/muesli/reflow/wordwrap"
+	"github.com/sergey-suslov/ai-notes/attach"
 	openaiclient "github.com/sergey-suslov/ai-notes/openai"
+	"github.com/sergey-suslov/ai-notes/redact"
 	"github.com/sergey-suslov/ai-notes/store"
+	"github.com/sergey-suslov/ai-notes/summarize"
+	"github.com/sergey-suslov/ai-notes/tools"
 	"github.com/sergey-suslov/ai-notes/util"
 )
 
 var BodyStyle = lipgloss.NewStyle().Margin(1, 2)
 
+// chatModel is the OpenAI model used for chat and note generation.
+const chatModel = openaiclient.DefaultModel
+
 // model holds the state for the chat UI.
 type model struct {
 	client   *openaiclient.Client
@@ -28,33 +38,104 @@ type model struct {
 	input    textarea.Model
 	viewport viewport.Model
 
+	// pending holds attachments staged with /attach for the next message
+	pending []store.Part
+
+	// titlePending is set while a session title is being generated
+	titlePending bool
+
+	// source is the note whose source messages are marked in the chat
+	source *store.Note
+
+	// rules select what is redacted from requests; nil disables redaction
+	rules []redact.Rule
+
+	// retry resends a request blocked by an exhausted budget once the user
+	// confirms with "y"; override is set while it builds the request.
+	retry    func(m model) (model, tea.Cmd)
+	override bool
+
+	// toolbox runs the tools offered to the assistant; nil disables them
+	toolbox *tools.Toolbox
+	// queue holds the tool calls of the last reply still to run; confirming
+	// is set while the first one waits for the user to allow it
+	queue      []store.ToolCall
+	confirming bool
+	// toolRounds counts the replies calling tools since the last user message
+	toolRounds int
+	// expandTools shows tool calls and results in full rather than one line each
+	expandTools bool
+
 	windowSize tea.WindowSizeMsg
 }
 
-// aiMsg wraps the AI's response content.
-type aiMsg string
+// aiMsg wraps the AI's response content and the usage of the request.
+type aiMsg struct {
+	content string
+	usage   store.Usage
+	// redactions logs what was redacted from each message of the request
+	redactions [][]store.Redaction
+	// toolCalls are the tools the reply asks to run before answering
+	toolCalls []store.ToolCall
+}
 
 // errMsg wraps errors from async commands.
 // errMsg wraps errors from async commands.
 type (
 	errMsg struct{ err error }
-	// noteMsg wraps generated note summary and file path.
-	noteMsg struct{ Summary, Path string }
+	// noteMsg wraps the generated note, its summary and file path.
+	noteMsg struct {
+		Summary, Path string
+		Note          *store.Note
+		Living        bool
+		// redactions logs what was redacted from each summarized message,
+		// starting at chat index start
+		redactions [][]store.Redaction
+		start      int
+	}
 	// noteErr wraps errors from note generation or saving.
 	noteErr struct{ err error }
+	// budgetErr reports a request blocked by an exhausted budget; retry
+	// sends it anyway.
+	budgetErr struct {
+		err   *openaiclient.BudgetError
+		retry func(m model) (model, tea.Cmd)
+	}
+	// toolResultMsg carries the result of a tool call, or the error it
+	// failed with.
+	toolResultMsg struct {
+		call   store.ToolCall
+		result string
+		err    error
+	}
+	// sessionTitleMsg carries a generated session title and description;
+	// err is set if generation failed.
+	sessionTitleMsg struct {
+		title, description string
+		err                error
+	}
+	// notesProgressMsg reports how many transcript parts of a long session
+	// have been summarized; status is the index of the chat message to update.
+	notesProgressMsg struct {
+		done, total int
+		status      int
+		ch          chan notesProgressMsg
+	}
 )
 
-// NewModel initializes the TUI model with  client and session
-func NewModel(client *openaiclient.Client, session *store.Session, initialWindopwSize tea.WindowSizeMsg) model {
+// NewModel initializes the TUI model with  client and session. rules select
+// what is redacted from requests; nil disables redaction. toolbox runs the
+// tools offered to the assistant; nil disables them.
+func NewModel(client *openaiclient.Client, session *store.Session, rules []redact.Rule, toolbox *tools.Toolbox, initialWindopwSize tea.WindowSizeMsg) model {
 	ti := textarea.New()
-	ti.Placeholder = "Type a message"
+	ti.Placeholder = "Type a message (@path or /attach <path> to attach files, /rename <title>, /notebook <name>, /redact to preview redaction, Ctrl+T to expand tool calls)"
 	ti.Focus()
 	ti.CharLimit = 1000
 	ti.SetWidth(initialWindopwSize.Width - 2)
 
 	// If this is a new session (no prior messages), add a welcome prompt
 	if len(session.Chat) == 0 {
-		session.Chat = append(session.Chat, store.Message{Role: "assistant", Content: "Welcome to AI Notes!"})
+		session.Chat = append(session.Chat, store.Message{Role: store.RoleStatus, Content: "Welcome to AI Notes!"})
 	}
 	vp := viewport.New(initialWindopwSize.Width-2, initialWindopwSize.Height-4)
 	vp.YPosition = 0
@@ -62,6 +143,8 @@ func NewModel(client *openaiclient.Client, session *store.Session, initialWindop
 
 	m := model{
 		client: client, session: session, input: ti,
+		rules:      rules,
+		toolbox:    toolbox,
 		viewport:   vp,
 		windowSize: initialWindopwSize,
 	}
@@ -78,6 +161,13 @@ func (m *model) defaultBodyMargin() (int, int) { //nolint:exhaustive
 }
 
 func (m *model) getChatString() string {
+	s, _ := m.renderChat()
+	return s
+}
+
+// renderChat renders the chat history and returns it with the line each
+// message starts at.
+func (m *model) renderChat() (string, []int) {
 	userStyle := lipgloss.NewStyle().Bold(true).Padding(1, 1).Margin(1, 2).Background(lipgloss.Color("#105fa8"))
 	aiStyle := lipgloss.NewStyle().Bold(false).Margin(1, 0).Border(lipgloss.NormalBorder(), true, false)
 	_, v := m.defaultBodyMargin()
@@ -90,21 +180,57 @@ func (m *model) getChatString() string {
 	)
 
 	var b strings.Builder
-	for _, msg := range m.session.Chat {
+	offsets := make([]int, len(m.session.Chat))
+	for i, msg := range m.session.Chat {
+		if m.source != nil && i == m.source.MessageStart {
+			b.WriteString("\n" + headerStyle.Render(fmt.Sprintf("▼ source of note %q", m.source.Title)) + "\n")
+		}
+		offsets[i] = strings.Count(b.String(), "\n")
 		// var prefix string
-		wrapped := wordwrap.String(msg.Content, m.viewport.Width-6)
+		wrapped := wordwrap.String(msg.Content+attachmentSummary(msg.Parts), m.viewport.Width-6)
 		switch msg.Role {
 		case "user":
 			b.WriteString(userStyle.Render(wrapped))
 		case "assistant":
+			if msg.Content != "" || len(msg.ToolCalls) == 0 {
+				content, _ := r.Render(msg.Content)
+				b.WriteString(aiStyle.Render(content))
+			}
+			for _, call := range msg.ToolCalls {
+				b.WriteString("\n" + m.renderToolCall(call, width) + "\n")
+			}
+		case store.RoleStatus:
 			content, _ := r.Render(msg.Content)
 			b.WriteString(aiStyle.Render(content))
+		case store.RoleTool:
+			b.WriteString(m.renderToolResult(msg, width) + "\n")
 		default:
 			b.WriteString(aiStyle.Render(wrapped))
 		}
 		// b.WriteString(prefixStyle.Render(prefix) + messageStyle.Render(msg.Content+"\n"))
+		if len(msg.Redactions) > 0 {
+			b.WriteString("\n" + dimStyle.Render("  "+redactionSummary(msg.Redactions)) + "\n")
+		}
+		if m.source != nil && i == m.source.MessageEnd-1 {
+			b.WriteString("\n" + headerStyle.Render("▲ end of source") + "\n")
+		}
 	}
-	return b.String()
+	if m.confirming {
+		b.WriteString("\n" + m.confirmPrompt(width) + "\n")
+	}
+	return b.String(), offsets
+}
+
+// showSource marks the messages note was generated from and scrolls to them.
+func (m *model) showSource(note *store.Note) {
+	if note.MessageEnd <= note.MessageStart || note.MessageEnd > len(m.session.Chat) {
+		return
+	}
+	m.source = note
+	content, offsets := m.renderChat()
+	m.viewport.SetContent(content)
+	// keep the source marker above the first message in view
+	m.viewport.SetYOffset(util.Max(0, offsets[note.MessageStart]-2))
 }
 
 // Init runs any initial IO; we only need blinking cursor.
@@ -124,35 +250,106 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.viewport.Height = msg.Height - 4
 
 	case noteMsg:
-		// append summary to chat
-		m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: msg.Summary})
+		setRedactions(m.session, msg.start, msg.redactions)
+		m.session.NotedUpTo = msg.Note.MessageEnd
+		saved := "Notes saved to %s"
+		if msg.Living {
+			m.session.LivingNoteID = msg.Note.ID
+			saved = "Living note updated at %s"
+		}
+		// append summary to chat; as a status line it is neither sent back
+		// to the model nor summarized again by the next delta or living note
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: msg.Summary})
 		// inform about saved file
-		m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: fmt.Sprintf("Notes saved to %s", msg.Path)})
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: fmt.Sprintf(saved, msg.Path)})
+		m.budgetWarnings()
 		m.viewport.SetContent(m.getChatString())
 		m.viewport.GotoBottom()
 
 		return m, nil
+	case notesProgressMsg:
+		if msg.status < len(m.session.Chat) {
+			m.session.Chat[msg.status].Content = fmt.Sprintf("Generating notes... %d/%d parts summarized", msg.done, msg.total)
+			m.viewport.SetContent(m.getChatString())
+			m.viewport.GotoBottom()
+		}
+		return m, waitNotesProgress(msg.ch, msg.status)
 	case noteErr:
-		m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: "Error generating notes: " + msg.err.Error()})
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Error generating notes: " + msg.err.Error()})
 		return m, nil
 	case aiMsg:
+		setRedactions(m.session, 0, msg.redactions)
 		// append AI reply
-		m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: string(msg)})
+		m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: msg.content, ToolCalls: msg.toolCalls})
+		m.session.UpdatedAt = time.Now()
+		m.session.Usage.Add(msg.usage)
+		if len(msg.toolCalls) > 0 {
+			// the reply continues once the tools have run
+			m.queue = msg.toolCalls
+			return m.nextTool()
+		}
+		m.toolRounds = 0
+		m.budgetWarnings()
 		m.viewport.SetContent(m.getChatString())
 		m.viewport.GotoBottom()
+		// name the session in the background after the first exchange
+		if m.session.Title == "" && !m.titlePending {
+			m.titlePending = true
+			return m, m.getTitleCmd()
+		}
+		return m, nil
+	case toolResultMsg:
+		return m.toolResult(msg)
+	case sessionTitleMsg:
+		m.titlePending = false
+		// a title set with /rename in the meantime wins
+		if msg.err == nil && m.session.Title == "" {
+			m.session.Title = msg.title
+			m.session.Description = msg.description
+		}
 		return m, nil
 	case errMsg:
-		m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: "Error: " + msg.err.Error()})
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Error: " + msg.err.Error()})
+		return m, nil
+	case budgetErr:
+		m.retry = msg.retry
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Request blocked: " + msg.err.Error() + ". Press y to send it anyway, any other key to cancel."})
+		m.viewport.SetContent(m.getChatString())
+		m.viewport.GotoBottom()
 		return m, nil
 	case tea.KeyMsg:
+		if m.retry != nil {
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			retry := m.retry
+			m.retry = nil
+			if msg.String() != "y" {
+				return m, nil
+			}
+			m.override = true
+			m, cmd := retry(m)
+			m.override = false
+			return m, cmd
+		}
+		if m.confirming {
+			switch msg.Type {
+			case tea.KeyCtrlC:
+				return m, tea.Quit
+			case tea.KeyEsc:
+				return m.cancelTools()
+			}
+			m.confirming = false
+			if msg.String() == "y" {
+				return m, m.runToolCmd(m.queue[0])
+			}
+			return m.toolResult(toolResultMsg{call: m.queue[0], result: "The user declined to run this tool call."})
+		}
 		switch msg.Type {
-		case tea.KeyCtrlN:
-			// trigger note generation
-			m.session.Chat = append(m.session.Chat, store.Message{Role: "assistant", Content: "Generating notes..."})
+		case tea.KeyCtrlT:
+			m.expandTools = !m.expandTools
 			m.viewport.SetContent(m.getChatString())
-			m.viewport.GotoBottom()
-
-			return m, m.getNotesCmd()
+			return m, nil
 		case tea.KeyCtrlC, tea.KeyEsc:
 			return m, tea.Quit
 		case tea.KeyCtrlS:
@@ -160,8 +357,39 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if strings.TrimSpace(userInput) == "" {
 				return m, nil
 			}
+			if paths, ok := strings.CutPrefix(strings.TrimSpace(userInput), "/attach"); ok {
+				m.input.Reset()
+				return m.stageAttachments(strings.Fields(paths)), nil
+			}
+			if title, ok := strings.CutPrefix(strings.TrimSpace(userInput), "/rename"); ok {
+				m.input.Reset()
+				return m.rename(strings.TrimSpace(title)), nil
+			}
+			if notebook, ok := strings.CutPrefix(strings.TrimSpace(userInput), "/notebook"); ok {
+				m.input.Reset()
+				return m.setNotebook(notebook), nil
+			}
+			if text, ok := strings.CutPrefix(strings.TrimSpace(userInput), "/redact"); ok {
+				m.input.Reset()
+				m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: m.redactPreview(strings.TrimSpace(text))})
+				m.viewport.SetContent(m.getChatString())
+				m.viewport.GotoBottom()
+				return m, nil
+			}
+			// resolve @path references and combine them with staged attachments
+			parts, err := m.loadAttachments(attach.References(userInput))
+			if err != nil {
+				m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Error attaching files: " + err.Error()})
+				m.viewport.SetContent(m.getChatString())
+				m.viewport.GotoBottom()
+				return m, nil
+			}
+			parts = append(m.pending, parts...)
+			m.pending = nil
 			// record user message
-			m.session.Chat = append(m.session.Chat, store.Message{Role: "user", Content: userInput})
+			m.session.Chat = append(m.session.Chat, store.Message{Role: "user", Content: userInput, Parts: parts})
+			m.session.UpdatedAt = time.Now()
+			m.toolRounds = 0
 			m.input.Reset()
 			m.viewport.SetContent(m.getChatString())
 			m.viewport.GotoBottom()
@@ -184,6 +412,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(vpCmd, inputCmd)
 }
 
+// prompting reports whether the chat waits for the answer to a prompt,
+// which then gets every key, Esc included.
+func (m model) prompting() bool {
+	return m.confirming || m.retry != nil
+}
+
 // View renders the chat history and the input field.
 func (m model) View() string {
 	var b strings.Builder
@@ -196,56 +430,330 @@ func (m model) View() string {
 	return b.String()
 }
 
+// generateNotes starts summarizing the session into a note as described by req.
+// Depending on its mode only the messages after the latest note are summarized.
+func (m model) generateNotes(req noteRequest) (model, tea.Cmd) {
+	tpl, mode := req.tpl, req.mode
+	if mode == noteLiving && m.session.LivingNoteID != "" {
+		// a trashed or deleted living note is replaced by a new one
+		if _, err := store.LoadNote(m.session.LivingNoteID); errors.Is(err, fs.ErrNotExist) {
+			m.session.LivingNoteID = ""
+			m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "The living note no longer exists; starting a new one."})
+		}
+	}
+	start, end := 0, len(m.session.Chat)
+	// the status message appended below is updated with summarization progress
+	status := end
+	if mode == noteDelta || (mode == noteLiving && m.session.LivingNoteID != "") {
+		start = m.session.NotedUpTo
+	}
+	// status lines written since the last note do not count as new
+	fresh := false
+	for _, cm := range m.session.Chat[min(start, end):end] {
+		fresh = fresh || openaiclient.Sent(cm)
+	}
+	if !fresh {
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "No new messages since the last note."})
+	} else {
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: fmt.Sprintf("Generating %s notes...", tpl.Name)})
+	}
+	m.viewport.SetContent(m.getChatString())
+	m.viewport.GotoBottom()
+	if !fresh {
+		return m, nil
+	}
+
+	progress := make(chan notesProgressMsg, 1)
+	return m, tea.Batch(m.getNotesCmd(req, start, end, progress), waitNotesProgress(progress, status))
+}
+
+// waitNotesProgress waits for the next progress report of a running note
+// generation. It returns nil once generation has finished.
+func waitNotesProgress(ch chan notesProgressMsg, status int) tea.Cmd {
+	return func() tea.Msg {
+		p, ok := <-ch
+		if !ok {
+			return nil
+		}
+		p.status = status
+		p.ch = ch
+		return p
+	}
+}
+
+// rename sets the session title.
+func (m model) rename(title string) model {
+	if title == "" {
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Usage: /rename <title>"})
+	} else {
+		m.session.Title = title
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Session renamed to " + title})
+	}
+	m.viewport.SetContent(m.getChatString())
+	m.viewport.GotoBottom()
+	return m
+}
+
+// setNotebook sets the notebook new notes of the session are filed in.
+func (m model) setNotebook(notebook string) model {
+	notebook = store.NormalizeNotebook(notebook)
+	m.session.Notebook = notebook
+	content := "New notes will be filed in notebook " + notebook
+	if notebook == "" {
+		content = "New notes will not be filed in a notebook. Usage: /notebook <name>"
+	}
+	m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: content})
+	m.viewport.SetContent(m.getChatString())
+	m.viewport.GotoBottom()
+	return m
+}
+
+// stageAttachments loads paths and keeps them until the next message is sent.
+func (m model) stageAttachments(paths []string) model {
+	if len(paths) == 0 {
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Usage: /attach <path> [path...]"})
+		m.viewport.SetContent(m.getChatString())
+		m.viewport.GotoBottom()
+		return m
+	}
+	parts, err := m.loadAttachments(paths)
+	if err != nil {
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Error attaching files: " + err.Error()})
+	} else {
+		m.pending = append(m.pending, parts...)
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Attached for the next message:" + attachmentSummary(parts)})
+	}
+	m.viewport.SetContent(m.getChatString())
+	m.viewport.GotoBottom()
+	return m
+}
+
+// loadAttachments turns paths into message parts: images are copied into the
+// session directory, everything else is read as text. The text shares one
+// token budget with the attachments already staged with /attach.
+func (m model) loadAttachments(paths []string) ([]store.Part, error) {
+	var parts []store.Part
+	var texts []string
+	for _, p := range paths {
+		if !attach.IsImage(p) {
+			texts = append(texts, p)
+			continue
+		}
+		img, err := m.session.AddImage(attach.ExpandHome(p))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, img)
+	}
+	files, err := attach.Load(texts, m.pending, attach.DefaultChunkTokens, attach.DefaultMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	return append(parts, files...), nil
+}
+
+// attachmentSummary lists the attachments of a message, one line per file.
+func attachmentSummary(parts []store.Part) string {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, p := range parts {
+		if seen[p.Hash+p.Filename] {
+			continue
+		}
+		seen[p.Hash+p.Filename] = true
+		switch p.Type {
+		case store.PartFile:
+			fmt.Fprintf(&b, "\n[attached: %s %s]", p.Filename, p.Hash[:8])
+		case store.PartImage:
+			fmt.Fprintf(&b, "\n[image: %s %dx%d]", p.Filename, p.Width, p.Height)
+		}
+	}
+	return b.String()
+}
+
 // getCompletionCmd builds a tea.Cmd that queries the OpenAI API with the full session context.
 func (m model) getCompletionCmd() tea.Cmd {
 	return func() tea.Msg {
-		ctx := context.Background()
+		ctx := m.requestContext()
 		// convert stored chat to openai messages
-		msgs := make([]goopenai.ChatCompletionMessage, len(m.session.Chat))
-		for i, cm := range m.session.Chat {
-			var role string
-			if cm.Role == "assistant" {
-				role = goopenai.ChatMessageRoleAssistant
-			} else {
-				role = goopenai.ChatMessageRoleUser
+		convert := openaiclient.SessionMessages
+		if m.toolbox != nil {
+			convert = openaiclient.ChatMessages
+		}
+		msgs, err := convert(m.session, m.session.Chat)
+		if err != nil {
+			return errMsg{err}
+		}
+		r, redactions := openaiclient.RedactMessages(m.rules, msgs)
+		var (
+			resp  string
+			usage goopenai.Usage
+			calls []store.ToolCall
+		)
+		if m.toolbox != nil {
+			// after maxToolRounds the model has to answer with what it has
+			var reply goopenai.ChatCompletionMessage
+			reply, usage, err = m.client.ChatCompletionTools(ctx, msgs, chatModel, m.toolbox.Definitions(), m.toolRounds >= maxToolRounds)
+			resp = reply.Content
+			for _, tc := range reply.ToolCalls {
+				calls = append(calls, store.ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: r.Restore(tc.Function.Arguments)})
 			}
-			msgs[i] = goopenai.ChatCompletionMessage{Role: role, Content: cm.Content}
+		} else {
+			resp, usage, err = m.client.ChatCompletionUsage(ctx, msgs, chatModel)
+		}
+		if be := (*openaiclient.BudgetError)(nil); errors.As(err, &be) {
+			return budgetErr{err: be, retry: func(m model) (model, tea.Cmd) {
+				return m, m.getCompletionCmd()
+			}}
 		}
-		resp, err := m.client.ChatCompletion(ctx, msgs, "gpt-4o-mini")
 		if err != nil {
 			return errMsg{err}
 		}
-		return aiMsg(resp)
+		return aiMsg{content: r.Restore(resp), redactions: redactions, toolCalls: calls, usage: store.Usage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Cost:             openaiclient.Cost(chatModel, usage),
+		}}
 	}
 }
 
-// getNotesCmd builds a tea.Cmd that generates bullet-point notes and saves them.
-func (m model) getNotesCmd() tea.Cmd {
+// getTitleCmd builds a tea.Cmd that generates a title and description for the session.
+func (m model) getTitleCmd() tea.Cmd {
+	chat := m.session.Chat
 	return func() tea.Msg {
-		ctx := context.Background()
-		// start with a system prompt
-		sys := goopenai.ChatCompletionMessage{Role: goopenai.ChatMessageRoleSystem, Content: "Please summarize the following conversation into concise bullet-point notes."}
-		msgs := make([]goopenai.ChatCompletionMessage, len(m.session.Chat)+1)
-		msgs[0] = sys
-		for i, cm := range m.session.Chat {
-			role := goopenai.ChatMessageRoleUser
-			if cm.Role == "assistant" {
-				role = goopenai.ChatMessageRoleAssistant
-			}
-			msgs[i+1] = goopenai.ChatCompletionMessage{Role: role, Content: cm.Content}
-		}
-		// get summary
-		summary, err := m.client.ChatCompletion(ctx, msgs, "gpt-4o-mini")
+		// images are not needed to name a session
+		msgs := openaiclient.TextMessages(chat)
+		r, _ := openaiclient.RedactMessages(m.rules, msgs)
+		title, description, err := summarize.SessionTitle(m.requestContext(), m.client, msgs, chatModel)
+		return sessionTitleMsg{title: r.Restore(title), description: r.Restore(description), err: err}
+	}
+}
+
+// getNotesCmd builds a tea.Cmd that generates notes from chat messages
+// [start, end) as described by req and saves them. In noteLiving mode the
+// session's living note is updated in place instead of creating a new note.
+// Long transcripts are summarized in parts, reporting on progress, which is
+// closed when the command finishes.
+func (m model) getNotesCmd(req noteRequest, start, end int, progress chan notesProgressMsg) tea.Cmd {
+	tpl, mode := req.tpl, req.mode
+	chat := m.session.Chat[start:end]
+	livingID := ""
+	if mode == noteLiving {
+		livingID = m.session.LivingNoteID
+	}
+	return func() tea.Msg {
+		defer close(progress)
+		ctx := m.requestContext()
+		// start with the template's system prompt
+		prompt := tpl.SystemPrompt(map[string]string{
+			"session_id": m.session.ID,
+			"date":       time.Now().Format("2006-01-02"),
+		})
+		var living *store.Note
+		if livingID != "" {
+			n, err := store.LoadNote(livingID)
+			if err != nil {
+				return noteErr{err}
+			}
+			living = n
+			prompt += "\n\nThe existing notes for this conversation are below. Merge the new material from the following messages into them, removing duplication, and return the complete updated notes.\n\n" + living.Markdown()
+		}
+		// images are re-sent with every chat message but not needed for notes
+		msgs := openaiclient.TextMessages(chat)
+		// the prompt may quote the living note, which holds restored values
+		r, redactions := openaiclient.RedactMessages(m.rules, msgs)
+		prompt, _ = r.Redact(prompt)
+		opts := summarize.Options{
+			Model: chatModel,
+			Progress: func(done, total int) {
+				select {
+				case progress <- notesProgressMsg{done: done, total: total}:
+				default:
+					// the UI has not caught up yet; drop this report
+				}
+			},
+		}
+		note := living
+		if note == nil {
+			note = store.NewNote(m.session.ID, "")
+			note.MessageStart = start
+			note.Notebook = m.session.Notebook
+		}
+		if req.structured {
+			e, err := summarize.Extract(ctx, m.client, prompt, msgs, opts)
+			if err != nil {
+				return notesFailed(req, err)
+			}
+			// keep items that were already checked off in the living note
+			done := make(map[string]bool)
+			for _, it := range note.ActionItems {
+				done[it.Text] = it.Done
+			}
+			e.Apply(note)
+			for i := range note.ActionItems {
+				note.ActionItems[i].Done = done[note.ActionItems[i].Text]
+			}
+		} else {
+			// get summary, in parts if the transcript is too long for one request
+			summary, err := summarize.Summarize(ctx, m.client, prompt, msgs, opts)
+			if err != nil {
+				return notesFailed(req, err)
+			}
+			note.Body = summary
+		}
+		notes, err := store.LoadNotes()
 		if err != nil {
 			return noteErr{err}
 		}
+		vocabulary := store.TagVocabulary(notes)
+		if req.structured {
+			note.Tags = store.NormalizeTags(note.Tags, vocabulary)
+		} else if living == nil {
+			// a readable title and tags are nice to have; keep the defaults on failure
+			if title, tags, err := summarize.TitleAndTags(ctx, m.client, note.Body, vocabulary, chatModel); err == nil {
+				note.Title = title
+				note.Tags = store.NormalizeTags(tags, vocabulary)
+			}
+		}
+		restoreNote(r, note)
+		summary := note.Markdown()
 		// save note
-		note := store.NewNote(m.session.ID, summary)
+		note.Template = tpl.Name
+		note.MessageEnd = end
 		path, err := note.Save()
 		if err != nil {
 			return noteErr{err}
 		}
-		return noteMsg{Summary: summary, Path: path}
+		return noteMsg{Summary: summary, Path: path, Note: note, Living: mode == noteLiving, redactions: redactions, start: start}
+	}
+}
+
+// notesFailed reports a failed note generation, offering to retry it if a
+// budget blocked it.
+func notesFailed(req noteRequest, err error) tea.Msg {
+	if be := (*openaiclient.BudgetError)(nil); errors.As(err, &be) {
+		return budgetErr{err: be, retry: func(m model) (model, tea.Cmd) {
+			return m.generateNotes(req)
+		}}
+	}
+	return noteErr{err}
+}
+
+// requestContext returns the context for the model's API requests, which
+// attributes them to the session and carries a confirmed budget override.
+func (m model) requestContext() context.Context {
+	ctx := openaiclient.WithSession(context.Background(), m.session.ID)
+	if m.override {
+		ctx = openaiclient.WithBudgetOverride(ctx)
+	}
+	return ctx
+}
+
+// budgetWarnings appends the budget warnings raised by recent requests to
+// the chat.
+func (m *model) budgetWarnings() {
+	for _, w := range m.client.TakeBudgetWarnings() {
+		m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Budget warning: " + w})
 	}
 }
 
@@ -0,0 +1,214 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sergey-suslov/ai-notes/store"
+	"github.com/sergey-suslov/ai-notes/util"
+)
+
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#5faf5f"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#d75f5f"))
+)
+
+// historyModel lists the revisions of a note, shows line diffs between them
+// and restores old versions. Entry 0 is the current version, entry i the
+// revision revs[i-1].
+type historyModel struct {
+	note   *store.Note
+	revs   []*store.Revision
+	cursor int
+	marked int // entry to diff against, -1 for the current version
+
+	// diff shows the diff between two entries in a viewport
+	diff     bool
+	diffFrom string
+	diffTo   string
+	viewport viewport.Model
+	ws       tea.WindowSizeMsg
+
+	restoring bool // waiting for restore confirmation
+	toast     toast
+	err       error
+}
+
+// newHistoryModel constructs a historyModel for note.
+func newHistoryModel(note *store.Note, ws tea.WindowSizeMsg) *historyModel {
+	m := &historyModel{note: note, marked: -1, ws: ws}
+	m.viewport = viewport.New(util.Max(0, ws.Width-2), util.Max(0, ws.Height-4))
+	m.viewport.MouseWheelEnabled = true
+	m.revs, m.err = note.Revisions()
+	return m
+}
+
+// capturesEsc reports whether Esc only closes the diff or a restore prompt.
+func (m *historyModel) capturesEsc() bool {
+	return m.diff || m.restoring
+}
+
+// label describes entry i of the list.
+func (m *historyModel) label(i int) string {
+	if i == 0 {
+		return "current version"
+	}
+	return m.revs[i-1].SavedAt.Format("2006-01-02 15:04:05")
+}
+
+// content returns the markdown of entry i.
+func (m *historyModel) content(i int) (string, error) {
+	note := m.note
+	if i > 0 {
+		var err error
+		if note, err = m.revs[i-1].Load(); err != nil {
+			return "", err
+		}
+	}
+	return "# " + note.Title + "\n\n" + note.Markdown(), nil
+}
+
+// showDiff renders the diff from the older to the newer of entries a and b.
+func (m *historyModel) showDiff(a, b int) {
+	if a < b {
+		a, b = b, a
+	}
+	from, err := m.content(a)
+	if err != nil {
+		m.err = err
+		return
+	}
+	to, err := m.content(b)
+	if err != nil {
+		m.err = err
+		return
+	}
+	var out strings.Builder
+	for _, l := range util.LineDiff(from, to) {
+		switch l.Kind {
+		case util.DiffAdded:
+			out.WriteString(addedStyle.Render("+ "+l.Text) + "\n")
+		case util.DiffRemoved:
+			out.WriteString(removedStyle.Render("- "+l.Text) + "\n")
+		default:
+			out.WriteString(dimStyle.Render("  "+l.Text) + "\n")
+		}
+	}
+	m.diff = true
+	m.diffFrom, m.diffTo = m.label(a), m.label(b)
+	m.viewport.SetContent(out.String())
+	m.viewport.GotoTop()
+}
+
+// Init is required by Bubble Tea; no initial command.
+func (m *historyModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles navigation, diffing and restoring.
+func (m *historyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.ws = msg
+		m.viewport.Width = util.Max(0, msg.Width-2)
+		m.viewport.Height = util.Max(0, msg.Height-4)
+	case toastExpiredMsg:
+		m.toast.expire(msg)
+	case tea.KeyMsg:
+		if m.diff {
+			if msg.Type == tea.KeyEsc || msg.Type == tea.KeyCtrlC {
+				m.diff = false
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
+		if m.restoring {
+			m.restoring = false
+			if msg.String() != "y" {
+				return m, nil
+			}
+			when := m.label(m.cursor)
+			if err := m.note.Restore(m.revs[m.cursor-1]); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.revs, m.err = m.note.Revisions()
+			m.cursor, m.marked = 0, -1
+			return m, m.toast.show(fmt.Sprintf("Restored the version from %s.", when))
+		}
+		switch msg.Type {
+		case tea.KeyUp:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case tea.KeyDown:
+			if m.cursor < len(m.revs) {
+				m.cursor++
+			}
+		case tea.KeySpace:
+			// mark the entry to compare others against
+			if m.marked == m.cursor {
+				m.marked = -1
+			} else {
+				m.marked = m.cursor
+			}
+		case tea.KeyEnter:
+			other := 0
+			if m.marked >= 0 {
+				other = m.marked
+			}
+			if other == m.cursor {
+				return m, nil
+			}
+			m.err = nil
+			m.showDiff(m.cursor, other)
+		case tea.KeyRunes:
+			if msg.String() == "r" && m.cursor > 0 {
+				m.err = nil
+				m.restoring = true
+			}
+		}
+	}
+	return m, nil
+}
+
+// View renders the revision list or the selected diff.
+func (m *historyModel) View() string {
+	var b strings.Builder
+	if m.diff {
+		b.WriteString(fmt.Sprintf("Changes from %s to %s\n\n", m.diffFrom, m.diffTo))
+		b.WriteString(m.viewport.View() + "\n\n")
+		b.WriteString("↑/↓ to scroll, esc to go back")
+		return b.String()
+	}
+	b.WriteString("History of " + m.note.Title + " (↑/↓, Enter to diff against the current or marked version, space to mark, r to restore, esc to go back):\n\n")
+	if len(m.revs) == 0 {
+		b.WriteString("  No earlier versions.\n")
+	}
+	for i := 0; i <= len(m.revs); i++ {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		mark := "[ ]"
+		if m.marked == i {
+			mark = "[x]"
+		}
+		b.WriteString(fmt.Sprintf("%s %s %s\n", cursor, mark, m.label(i)))
+	}
+	if m.restoring {
+		b.WriteString(fmt.Sprintf("\nRestore the version from %s? (y/n)\n", m.label(m.cursor)))
+	}
+	if m.toast.text != "" {
+		b.WriteString("\n" + m.toast.text + "\n")
+	}
+	if m.err != nil {
+		b.WriteString("\nError: " + m.err.Error() + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// noteRow is a line of the notes tree: a notebook or a note.
+type noteRow struct {
+	notebook string      // notebook path of a notebook row
+	note     *store.Note // nil for notebook rows
+	depth    int
+	count    int // notes in the notebook and its sub-notebooks
+}
+
+// buildRows lays out m.notes as a tree below the scoped notebook. Each
+// notebook lists its sub-notebooks first, then its notes; the contents of
+// collapsed notebooks are hidden.
+func (m *notesModel) buildRows() []noteRow {
+	children := make(map[string][]string)
+	for _, nb := range store.Notebooks(m.notes) {
+		if nb != m.notebook && (m.notebook == "" || strings.HasPrefix(nb, m.notebook+"/")) {
+			parent := store.ParentNotebook(nb)
+			children[parent] = append(children[parent], nb)
+		}
+	}
+	var rows []noteRow
+	var walk func(notebook string, depth int)
+	walk = func(notebook string, depth int) {
+		for _, nb := range children[notebook] {
+			rows = append(rows, noteRow{notebook: nb, depth: depth, count: len(store.FilterNotebook(m.notes, nb))})
+			if !m.collapsed[nb] {
+				walk(nb, depth+1)
+			}
+		}
+		for _, n := range m.notes {
+			if n.Notebook == notebook {
+				rows = append(rows, noteRow{note: n, depth: depth})
+			}
+		}
+	}
+	walk(m.notebook, 0)
+	return rows
+}
+
+// current returns the highlighted note, or nil on a notebook row.
+func (m *notesModel) current() *store.Note {
+	if m.cursor >= len(m.rows) {
+		return nil
+	}
+	return m.rows[m.cursor].note
+}
+
+// currentNotebook returns the highlighted notebook, or "" on a note row.
+func (m *notesModel) currentNotebook() string {
+	if m.cursor >= len(m.rows) {
+		return ""
+	}
+	return m.rows[m.cursor].notebook
+}
+
+// matches reports whether the note contains every word of the search query.
+func (m *notesModel) matches(n *store.Note) bool {
+	return n.Matches(m.query)
+}
@@ -1,62 +1,207 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
+	"github.com/sergey-suslov/ai-notes/export"
+	openaiclient "github.com/sergey-suslov/ai-notes/openai"
+	"github.com/sergey-suslov/ai-notes/redact"
 	"github.com/sergey-suslov/ai-notes/store"
+	"github.com/sergey-suslov/ai-notes/summarize"
 	"github.com/sergey-suslov/ai-notes/util"
 )
 
 // notesModel lets the user browse and select notes to inject.
 // notesModel lets the user browse and select notes to inject or view.
 type notesModel struct {
-	notes    []*store.Note
+	client   *openaiclient.Client
+	rules    []redact.Rule  // what is redacted from requests, nil for nothing
+	all      []*store.Note  // every loaded note
+	notes    []*store.Note  // notes matching filter
+	filter   string         // tag the list is filtered by, "" for all notes
+	session  *store.Session // session the list is limited to, nil for all
+	notebook string         // notebook the list is scoped to, "" for all notes
+	query    string         // search words, "" for no search
 	cursor   int
 	selected *store.Note
-	action   string // "inject" or "view"
+	inject   []*store.Note // notes to inject with action "inject"
+	action   string        // "inject", "view", "actions" or "tags"
+
+	// rows is the notebook tree of notes, collapsed holds folded notebooks
+	rows      []noteRow
+	collapsed map[string]bool
+
+	// marked holds the notes selected with space for merging
+	marked  map[*store.Note]bool
+	merging bool
+	// retryMerge holds the notes whose merge a budget blocked while the
+	// user is asked whether to merge them anyway
+	retryMerge []*store.Note
+
+	// editing is "tags", "title" or "notebook" while the highlighted note or
+	// notebook is edited, "search" while the search query is typed and
+	// "export" while the export format is chosen
+	editing  string
+	tagInput textinput.Model
+	err      error
+
+	// confirming is the note awaiting delete confirmation
+	confirming *store.Note
+	// relinking is the renamed note whose incoming links may be rewritten
+	relinking *relink
+	// undo restores the last trashed note while its toast is visible
+	undo     *store.TrashItem
+	undoNote *store.Note
+	toast    toast
 }
 
 // viewModel displays a single note in read-only mode.
 // viewExitMsg signals exiting the note view.
 type (
 	viewExitMsg struct{}
+	// viewHistoryMsg asks to open the history of the viewed note.
+	viewHistoryMsg struct{}
+	// viewSessionMsg asks to open the session the viewed note came from.
+	viewSessionMsg struct{}
 	// viewModel holds the note to display.
 	viewModel struct {
 		note     *store.Note
+		notes    []*store.Note // every note, to resolve links against
+		back     []*store.Note // notes left by following links
+		links    []string      // link targets in the displayed note
+		focus    int           // index of the focused link, -1 for none
+		err      error
 		viewport viewport.Model
 		ws       tea.WindowSizeMsg
 	}
 )
 
 // newViewModel creates a viewModel for the given note.
-func newViewModel(note *store.Note, initialWindopwSize tea.WindowSizeMsg) viewModel {
+func newViewModel(note *store.Note, notes []*store.Note, initialWindopwSize tea.WindowSizeMsg) viewModel {
 	vp := viewport.New(initialWindopwSize.Width-2, initialWindopwSize.Height-4)
 	vp.YPosition = 0
 	vp.MouseWheelEnabled = true
 
-	vp.SetContent(note.Body)
-	return viewModel{note: note, viewport: vp, ws: initialWindopwSize}
+	m := viewModel{note: note, notes: notes, viewport: vp, ws: initialWindopwSize}
+	m.open(note)
+	return m
+}
+
+// open shows note with no link focused.
+func (m *viewModel) open(note *store.Note) {
+	m.note = note
+	m.links = store.LinkTargets(m.markdown())
+	m.focus = -1
+	m.viewport.GotoTop()
+	m.render()
+}
+
+// markdown returns the note followed by its backlinks.
+func (m *viewModel) markdown() string {
+	md := m.note.Markdown()
+	if backlinks := store.Backlinks(m.notes, m.note); len(backlinks) > 0 {
+		md += "\n\n## Backlinks\n"
+		for _, n := range backlinks {
+			md += "\n- [[" + n.Title + "]]"
+		}
+	}
+	return md
+}
+
+// render renders the note into the viewport, showing resolved links in bold
+// and the focused link as code.
+func (m *viewModel) render() {
+	width := util.Max(0, util.Min(int(180), m.ws.Width-2))
+	r, _ := glamour.NewTermRenderer(
+		glamour.WithStandardStyle("dark"),
+		glamour.WithWordWrap(width),
+	)
+	focused := ""
+	if m.focus >= 0 {
+		focused = m.links[m.focus]
+	}
+	md := store.ReplaceLinks(m.markdown(), func(target string) string {
+		link := "[[" + target + "]]"
+		switch {
+		case strings.EqualFold(target, focused):
+			return "`" + link + "`"
+		case store.ResolveLink(m.notes, target) != nil:
+			return "**" + link + "**"
+		}
+		return link
+	})
+	f, _ := r.Render(md)
+	m.viewport.SetContent(f)
+}
+
+// follow opens the note the focused link points to.
+func (m *viewModel) follow() {
+	if m.focus < 0 {
+		return
+	}
+	target := store.ResolveLink(m.notes, m.links[m.focus])
+	if target == nil {
+		m.err = fmt.Errorf("no note is titled %q", m.links[m.focus])
+		return
+	}
+	m.back = append(m.back, m.note)
+	m.open(target)
 }
 
 // Init does nothing for viewModel.
 func (m viewModel) Init() tea.Cmd { return nil }
 
-// Update exits view on any key press.
+// Update scrolls the note, cycles and follows links, opens the note's
+// history on h or its source session on s and goes back or exits on Esc.
 func (m viewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.ws = msg
 		m.viewport.Width = util.Max(0, msg.Width-2)
 		m.viewport.Height = util.Max(0, msg.Height-4)
+		m.render()
 
 	case tea.KeyMsg:
+		m.err = nil
 		switch msg.Type {
 		case tea.KeyEsc, tea.KeyCtrlC:
+			// return to the note a link was followed from
+			if n := len(m.back); n > 0 {
+				prev := m.back[n-1]
+				m.back = m.back[:n-1]
+				m.open(prev)
+				return m, nil
+			}
 			return m, func() tea.Msg { return viewExitMsg{} }
+		case tea.KeyTab, tea.KeyShiftTab:
+			if len(m.links) == 0 {
+				return m, nil
+			}
+			if msg.Type == tea.KeyTab {
+				m.focus = (m.focus + 1) % len(m.links)
+			} else {
+				m.focus = (m.focus - 1 + len(m.links)) % len(m.links)
+			}
+			m.render()
+			return m, nil
+		case tea.KeyEnter:
+			m.follow()
+			return m, nil
+		case tea.KeyRunes:
+			switch msg.String() {
+			case "h":
+				return m, func() tea.Msg { return viewHistoryMsg{} }
+			case "s":
+				return m, func() tea.Msg { return viewSessionMsg{} }
+			}
 		}
 	}
 	var vpCmd tea.Cmd
@@ -66,32 +211,363 @@ func (m viewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // View renders the note title and body.
 func (m viewModel) View() string {
-	// m.ws = tea.WindowSizeMsg{Width: 171, Height: 20}
-	width := util.Max(0, util.Min(int(180), m.ws.Width-2))
-
-	r, _ := glamour.NewTermRenderer(
-		glamour.WithStandardStyle("dark"),
-		glamour.WithWordWrap(width),
-	)
 	var b strings.Builder
+	b.WriteString("Viewing Note: " + m.note.Title)
+	if r := m.note.SourceRange(); r != "" {
+		b.WriteString(" (" + r + ")")
+	}
+	b.WriteString("\n\n")
+	b.WriteString(m.viewport.View() + "\n\n")
+	if m.err != nil {
+		b.WriteString("Error: " + m.err.Error() + "\n")
+	}
+	b.WriteString("tab/shift+tab to select a link, enter to follow, h for history, s for the source session, esc to go back")
+	return b.String()
+}
 
-	f, _ := r.Render(m.note.Body)
+// relink is a pending offer to rewrite the links to a renamed note.
+type relink struct {
+	note     *store.Note
+	oldTitle string
+	count    int // notes linking to the old title
+}
 
-	// Update viewport dimensions based on window size
-	m.viewport.Width = util.Max(0, m.ws.Width-2)
-	m.viewport.Height = util.Max(0, m.ws.Height-4)
-	m.viewport.SetContent(f)
+// notesMergedMsg carries the note created by merging the marked notes.
+type notesMergedMsg struct {
+	note *store.Note
+	err  error
+	// blocked holds the notes to merge if a budget blocked the merge
+	blocked []*store.Note
+}
 
-	b.WriteString("Viewing Note: " + m.note.Title + "\n\n")
-	b.WriteString(m.viewport.View() + "\n\n")
-	b.WriteString("Press any key to return...")
-	return b.String()
+// newNotesModel constructs a notesModel from stored notes. rules select
+// what is redacted from merge requests.
+func newNotesModel(client *openaiclient.Client, rules []redact.Rule, notes []*store.Note) *notesModel {
+	ti := textinput.New()
+	m := &notesModel{client: client, rules: rules, all: notes, action: "", tagInput: ti, marked: map[*store.Note]bool{}, collapsed: map[string]bool{}}
+	m.setFilter("")
+	return m
+}
+
+// setFilter shows only notes tagged with tag, or all notes if tag is "".
+// Notes outside the session, notebook and search the list is limited to
+// are never shown.
+func (m *notesModel) setFilter(tag string) {
+	m.filter = tag
+	m.cursor = 0
+	m.notes = nil
+	for _, n := range m.all {
+		if (tag == "" || n.HasTag(tag)) && (m.session == nil || n.SessionID == m.session.ID) && n.InNotebook(m.notebook) && m.matches(n) {
+			m.notes = append(m.notes, n)
+		}
+	}
+	m.rows = m.buildRows()
+}
+
+// setNotebook scopes the list to a notebook, "" for all notes.
+func (m *notesModel) setNotebook(notebook string) {
+	m.notebook = notebook
+	m.setFilter(m.filter)
+}
+
+// setSession limits the list to the notes of a session.
+func (m *notesModel) setSession(s *store.Session) {
+	m.session = s
+	m.setFilter(m.filter)
+}
+
+// capturesEsc reports whether Esc only cancels editing or a delete prompt.
+func (m *notesModel) capturesEsc() bool {
+	return m.editing != "" || m.confirming != nil || m.relinking != nil || m.retryMerge != nil
+}
+
+// updateRelink handles the prompt to rewrite links to a renamed note.
+func (m *notesModel) updateRelink(msg tea.Msg) (tea.Model, tea.Cmd) {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	rl := m.relinking
+	m.relinking = nil
+	if k.String() != "y" {
+		return m, nil
+	}
+	changed, err := store.RewriteLinks(m.all, rl.oldTitle, rl.note.Title)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	return m, m.toast.show(fmt.Sprintf("Updated links in %d notes.", len(changed)))
+}
+
+// edit starts editing the tags, title or notebook of the highlighted note,
+// or the search query.
+func (m *notesModel) edit(field string) tea.Cmd {
+	note := m.current()
+	m.editing = field
+	m.err = nil
+	m.tagInput.Placeholder = ""
+	switch field {
+	case "title":
+		m.tagInput.Prompt = "Title: "
+		m.tagInput.SetValue(note.Title)
+	case "notebook":
+		m.tagInput.Prompt = "Move to notebook: "
+		m.tagInput.Placeholder = "path/to/notebook, empty for none"
+		if note != nil {
+			m.tagInput.SetValue(note.Notebook)
+		} else {
+			m.tagInput.SetValue(m.currentNotebook())
+		}
+	case "search":
+		m.tagInput.Prompt = "Search: "
+		m.tagInput.SetValue(m.query)
+	case "export":
+		m.tagInput.Prompt = "Export as (" + strings.Join(export.Names(), ", ") + "): "
+		m.tagInput.SetValue(export.DefaultFormat)
+	default:
+		m.tagInput.Prompt = "Tags: "
+		m.tagInput.Placeholder = "comma-separated"
+		m.tagInput.SetValue(strings.Join(note.Tags, ", "))
+	}
+	m.tagInput.CursorEnd()
+	return m.tagInput.Focus()
+}
+
+// export writes the marked notes, or else the highlighted note or every note
+// of the highlighted notebook, to the export directory in format.
+func (m *notesModel) export(format string) tea.Cmd {
+	f, err := export.Lookup(format)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	notes := m.markedNotes()
+	if len(notes) == 0 {
+		if note := m.current(); note != nil {
+			notes = []*store.Note{note}
+		} else {
+			notes = store.FilterNotebook(m.notes, m.currentNotebook())
+		}
+	}
+	dir, err := export.DefaultDir()
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	paths, err := export.Notes(dir, f, notes)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	if len(paths) == 1 {
+		return m.toast.show("Exported to " + paths[0] + ".")
+	}
+	return m.toast.show(fmt.Sprintf("Exported %d notes to %s.", len(paths), dir))
+}
+
+// move files the marked notes, or else the highlighted note or notebook,
+// in notebook.
+func (m *notesModel) move(notebook string) tea.Cmd {
+	notebook = store.NormalizeNotebook(notebook)
+	var moved []*store.Note
+	if from := m.currentNotebook(); from != "" && len(m.marked) == 0 {
+		var err error
+		if moved, err = store.MoveNotebook(m.all, from, notebook); err != nil {
+			m.err = err
+		}
+	} else {
+		notes := m.markedNotes()
+		if len(notes) == 0 {
+			notes = []*store.Note{m.current()}
+		}
+		for _, n := range notes {
+			old := n.Notebook
+			if old == notebook {
+				continue
+			}
+			n.Notebook = notebook
+			if _, err := n.Save(); err != nil {
+				n.Notebook = old
+				m.err = err
+				break
+			}
+			moved = append(moved, n)
+		}
+		m.marked = map[*store.Note]bool{}
+	}
+	m.setFilter(m.filter)
+	if len(moved) == 0 {
+		return nil
+	}
+	where := "notebook " + notebook
+	if notebook == "" {
+		where = "no notebook"
+	}
+	return m.toast.show(fmt.Sprintf("Moved %d notes to %s.", len(moved), where))
 }
 
-// newNotesModel constructs a notesModel from stored notes.
-// newNotesModel constructs a notesModel from stored notes.
-func newNotesModel(notes []*store.Note) *notesModel {
-	return &notesModel{notes: notes, action: ""}
+// addNote adds a newly saved note to the list, keeping it newest first.
+func (m *notesModel) addNote(n *store.Note) {
+	m.all = append(m.all, n)
+	sort.Slice(m.all, func(i, j int) bool { return m.all[i].CreatedAt.After(m.all[j].CreatedAt) })
+	m.setFilter(m.filter)
+}
+
+// markedNotes returns the marked notes in list order.
+func (m *notesModel) markedNotes() []*store.Note {
+	var out []*store.Note
+	for _, n := range m.all {
+		if m.marked[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// mergeNotesCmd merges notes into a new saved note. The merged note keeps
+// the union of their tags and lists the originals in a Sources section.
+// The notes are redacted like chat messages before they are sent; with
+// override set the request is sent even if a budget is exhausted.
+func (m *notesModel) mergeNotesCmd(notes []*store.Note, override bool) tea.Cmd {
+	client, rules, all := m.client, m.rules, m.all
+	// keep the session and notebook the notes have in common
+	sessionID, notebook := notes[0].SessionID, notes[0].Notebook
+	for _, n := range notes[1:] {
+		if n.SessionID != sessionID {
+			sessionID = ""
+		}
+		if n.Notebook != notebook {
+			notebook = ""
+		}
+	}
+	// requests are attributed to that session, or to the one the list is
+	// limited to
+	requestSession := sessionID
+	if requestSession == "" && m.session != nil {
+		requestSession = m.session.ID
+	}
+	return func() tea.Msg {
+		ctx := openaiclient.WithSession(context.Background(), requestSession)
+		if override {
+			ctx = openaiclient.WithBudgetOverride(ctx)
+		}
+		msgs := summarize.NoteMessages(notes)
+		r, _ := openaiclient.RedactMessages(rules, msgs)
+		body, err := summarize.MergeNotes(ctx, client, msgs, chatModel)
+		if err != nil {
+			if be := (*openaiclient.BudgetError)(nil); errors.As(err, &be) {
+				return notesMergedMsg{err: be, blocked: notes}
+			}
+			return notesMergedMsg{err: fmt.Errorf("merging notes: %w", err)}
+		}
+		var sources strings.Builder
+		sources.WriteString("\n\n## Sources\n")
+		var tags []string
+		for _, n := range notes {
+			sources.WriteString("\n- [[" + n.Title + "]]")
+			tags = append(tags, n.Tags...)
+		}
+		note := store.NewNote(sessionID, "")
+		note.Notebook = notebook
+		note.Title = "Merged notes"
+		for _, n := range notes {
+			note.MergedFrom = append(note.MergedFrom, n.ID)
+		}
+		vocabulary := store.TagVocabulary(all)
+		// the body still holds placeholders, so the title is asked for first
+		if title, _, err := summarize.TitleAndTags(ctx, client, body, vocabulary, chatModel); err == nil {
+			note.Title = title
+		}
+		note.Body = body
+		restoreNote(r, note)
+		note.Body += sources.String()
+		note.Tags = store.NormalizeTags(tags, vocabulary)
+		if _, err := note.Save(); err != nil {
+			return notesMergedMsg{err: err}
+		}
+		return notesMergedMsg{note: note}
+	}
+}
+
+// updateConfirm handles the delete confirmation prompt.
+func (m *notesModel) updateConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	note := m.confirming
+	m.confirming = nil
+	if k.String() != "y" {
+		return m, nil
+	}
+	item, err := note.Trash()
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.all = removeNote(m.all, note)
+	m.setFilter(m.filter)
+	m.undo, m.undoNote = item, note
+	return m, m.toast.show(fmt.Sprintf("Moved %q to trash. Press u to undo.", item.Title))
+}
+
+// removeNote returns notes without n.
+func removeNote(notes []*store.Note, n *store.Note) []*store.Note {
+	out := make([]*store.Note, 0, len(notes))
+	for _, o := range notes {
+		if o != n {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// updateEdit handles keys while a note, notebook or the search query is edited.
+func (m *notesModel) updateEdit(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		switch k.Type {
+		case tea.KeyEnter:
+			field := m.editing
+			m.editing = ""
+			m.tagInput.Blur()
+			switch field {
+			case "search":
+				m.query = strings.TrimSpace(m.tagInput.Value())
+				m.setFilter(m.filter)
+				return m, nil
+			case "notebook":
+				return m, m.move(m.tagInput.Value())
+			case "export":
+				return m, m.export(strings.TrimSpace(m.tagInput.Value()))
+			}
+			note := m.current()
+			oldTags, oldTitle := note.Tags, note.Title
+			backlinks := store.Backlinks(m.all, note)
+			if field == "title" {
+				if title := strings.TrimSpace(m.tagInput.Value()); title != "" {
+					note.Title = title
+				}
+			} else {
+				note.Tags = store.NormalizeTags(strings.Split(m.tagInput.Value(), ","), store.TagVocabulary(m.all))
+			}
+			if _, err := note.Save(); err != nil {
+				note.Tags, note.Title = oldTags, oldTitle
+				m.err = err
+			} else if note.Title != oldTitle && len(backlinks) > 0 {
+				// offer to point incoming links at the new title
+				m.relinking = &relink{note: note, oldTitle: oldTitle, count: len(backlinks)}
+			}
+			return m, nil
+		case tea.KeyEsc, tea.KeyCtrlC:
+			m.editing = ""
+			m.tagInput.Blur()
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.tagInput, cmd = m.tagInput.Update(msg)
+	return m, cmd
 }
 
 // Init is required by Bubble Tea; no initial command.
@@ -101,7 +577,44 @@ func (m *notesModel) Init() tea.Cmd {
 
 // Update handles navigation and selection.
 func (m *notesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.editing != "" {
+		return m.updateEdit(msg)
+	}
+	if m.confirming != nil {
+		return m.updateConfirm(msg)
+	}
+	if m.relinking != nil {
+		return m.updateRelink(msg)
+	}
+	if k, ok := msg.(tea.KeyMsg); ok && m.retryMerge != nil {
+		notes := m.retryMerge
+		m.retryMerge = nil
+		m.err = nil
+		if k.String() != "y" {
+			return m, nil
+		}
+		m.merging = true
+		return m, m.mergeNotesCmd(notes, true)
+	}
 	switch msg := msg.(type) {
+	case toastExpiredMsg:
+		if m.toast.expire(msg) {
+			m.undo, m.undoNote = nil, nil
+		}
+	case notesMergedMsg:
+		m.merging = false
+		if msg.blocked != nil {
+			m.retryMerge = msg.blocked
+			m.err = fmt.Errorf("merge blocked: %w. Press y to merge anyway, any other key to cancel", msg.err)
+			return m, nil
+		}
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.marked = map[*store.Note]bool{}
+		m.addNote(msg.note)
+		return m, m.toast.show(fmt.Sprintf("Merged into %q.", msg.note.Title))
 	case tea.KeyMsg:
 		switch msg.Type {
 		case tea.KeyUp:
@@ -109,20 +622,142 @@ func (m *notesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor--
 			}
 		case tea.KeyDown:
-			if m.cursor < len(m.notes)-1 {
+			if m.cursor < len(m.rows)-1 {
 				m.cursor++
 			}
+		case tea.KeyBackspace:
+			// leave the scoped notebook for its parent
+			if m.notebook != "" {
+				m.setNotebook(store.ParentNotebook(m.notebook))
+			}
 		case tea.KeyRunes:
-			// handle 'a' for inject
+			// handle '#' for the tag browser
+			if len(msg.Runes) > 0 && msg.Runes[0] == '#' {
+				m.action = "tags"
+				return m, nil
+			}
+			// handle '/' to search the notes in scope
+			if len(msg.Runes) > 0 && msg.Runes[0] == '/' {
+				return m, m.edit("search")
+			}
+			// handle 'g' to export the link graph of the notes in scope
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'g' {
+				path, err := store.WriteLinkGraph(store.FilterNotebook(m.all, m.notebook))
+				if err != nil {
+					m.err = err
+					return m, nil
+				}
+				return m, m.toast.show("Exported the link graph to " + path + ".")
+			}
+			// handle 'u' to undo the last delete
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'u' && m.undo != nil {
+				if err := m.undo.Restore(); err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.addNote(m.undoNote)
+				m.undo, m.undoNote = nil, nil
+				return m, m.toast.show("Restored from trash.")
+			}
+			// handle 't' for the action items screen
+			if len(msg.Runes) > 0 && msg.Runes[0] == 't' {
+				m.action = "actions"
+				return m, nil
+			}
+			// handle 'm' to merge the marked notes
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'm' {
+				marked := m.markedNotes()
+				if len(marked) < 2 {
+					m.err = fmt.Errorf("mark at least two notes with space to merge them")
+					return m, nil
+				}
+				if m.merging {
+					return m, nil
+				}
+				m.err = nil
+				m.merging = true
+				return m, m.mergeNotesCmd(marked, false)
+			}
+			if len(m.rows) == 0 {
+				return m, nil
+			}
+			// handle 'v' to move the marked or highlighted notes to a notebook
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'v' {
+				return m, m.edit("notebook")
+			}
+			// handle 'x' to export the marked or highlighted notes
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'x' {
+				return m, m.edit("export")
+			}
+			// handle 'a' to inject the note or every note of the notebook
 			if len(msg.Runes) > 0 && msg.Runes[0] == 'a' {
+				if note := m.current(); note != nil {
+					m.inject = []*store.Note{note}
+				} else {
+					m.inject = store.FilterNotebook(m.notes, m.currentNotebook())
+				}
 				m.action = "inject"
-				m.selected = m.notes[m.cursor]
-				return m, tea.Quit
+				return m, nil
+			}
+			// handle 'o' to scope the list to the highlighted notebook
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'o' {
+				if nb := m.currentNotebook(); nb != "" {
+					m.setNotebook(nb)
+				}
+				return m, nil
+			}
+			note := m.current()
+			if note == nil {
+				return m, nil
+			}
+			// handle 'd' to move the note to the trash
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'd' {
+				m.err = nil
+				m.confirming = note
+				return m, nil
+			}
+			// handle 'e' to edit the note's tags
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'e' {
+				return m, m.edit("tags")
+			}
+			// handle 'r' to rename the note
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'r' {
+				return m, m.edit("title")
+			}
+			// handle 'c' to duplicate the note
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'c' {
+				dup := note.Duplicate()
+				if _, err := dup.Save(); err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.addNote(dup)
+				return m, m.toast.show(fmt.Sprintf("Created %q.", dup.Title))
+			}
+		case tea.KeySpace:
+			// mark the note for merging or moving
+			note := m.current()
+			if note == nil {
+				return m, nil
+			}
+			if m.marked[note] {
+				delete(m.marked, note)
+			} else {
+				m.marked[note] = true
 			}
 		case tea.KeyEnter:
+			if len(m.rows) == 0 {
+				return m, nil
+			}
+			// fold or unfold a notebook
+			if nb := m.currentNotebook(); nb != "" {
+				m.collapsed[nb] = !m.collapsed[nb]
+				m.rows = m.buildRows()
+				return m, nil
+			}
 			// view note
 			m.action = "view"
-			m.selected = m.notes[m.cursor]
+			m.selected = m.current()
 			return m, tea.Quit
 		case tea.KeyEsc, tea.KeyCtrlC:
 			// cancel and return to chat
@@ -134,17 +769,66 @@ func (m *notesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// View renders the list of notes.
+// View renders the notebook tree of notes.
 func (m *notesModel) View() string {
 	var b strings.Builder
-	b.WriteString("Select a note (↑/↓, Enter to view, a to inject, esc to cancel):\n\n")
-	for i, note := range m.notes {
+	b.WriteString("Select a note (↑/↓, Enter to view or fold a notebook, a to inject, o to open a notebook, backspace for its parent, v to move, x to export, / to search, e to edit tags, r to rename, c to duplicate, d to delete, space to mark, m to merge marked, g to export the link graph, # to browse tags, t for action items, esc to cancel):\n")
+	if m.session != nil {
+		b.WriteString("Session: " + m.session.DisplayTitle() + "\n")
+	}
+	if m.notebook != "" {
+		b.WriteString("Notebook: " + m.notebook + "\n")
+	}
+	if m.filter != "" {
+		b.WriteString("Tag: #" + m.filter + "\n")
+	}
+	if m.query != "" {
+		b.WriteString("Search: " + m.query + "\n")
+	}
+	b.WriteString("\n")
+	for i, row := range m.rows {
 		cursor := " "
 		if m.cursor == i {
 			cursor = ">"
 		}
-		b.WriteString(fmt.Sprintf("%s %s (%s)\n", cursor, note.Title, note.CreatedAt.Format("2006-01-02 15:04:05")))
+		indent := strings.Repeat("  ", row.depth)
+		if row.note == nil {
+			fold := "▾"
+			if m.collapsed[row.notebook] {
+				fold = "▸"
+			}
+			name := row.notebook[strings.LastIndex(row.notebook, "/")+1:]
+			b.WriteString(fmt.Sprintf("%s %s%s %s (%d)\n", cursor, indent, fold, headerStyle.Render(name), row.count))
+			continue
+		}
+		note := row.note
+		mark := "[ ]"
+		if m.marked[note] {
+			mark = "[x]"
+		}
+		tags := ""
+		if len(note.Tags) > 0 {
+			tags = " #" + strings.Join(note.Tags, " #")
+		}
+		b.WriteString(fmt.Sprintf("%s %s%s %s (%s)%s\n", cursor, indent, mark, note.Title, note.CreatedAt.Format("2006-01-02 15:04:05"), tags))
+	}
+	if m.merging {
+		b.WriteString("\nMerging notes...\n")
+	}
+	if m.editing != "" {
+		b.WriteString("\n" + m.tagInput.View() + "\n")
+	}
+	if m.confirming != nil {
+		b.WriteString(fmt.Sprintf("\nMove %q to trash? (y/n)\n", m.confirming.Title))
+	}
+	if m.relinking != nil {
+		b.WriteString(fmt.Sprintf("\nUpdate links to %q in %d notes? (y/n)\n", m.relinking.oldTitle, m.relinking.count))
+	}
+	if m.toast.text != "" {
+		b.WriteString("\n" + m.toast.text + "\n")
+	}
+	if m.err != nil {
+		b.WriteString("\nError: " + m.err.Error() + "\n")
 	}
 	return b.String()
 }
-
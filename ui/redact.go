@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	goopenai "github.com/sashabaranov/go-openai"
+
+	openaiclient "github.com/sergey-suslov/ai-notes/openai"
+	"github.com/sergey-suslov/ai-notes/redact"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// setRedactions records the redaction log of a request on the messages it
+// was built from, starting at chat index start.
+func setRedactions(session *store.Session, start int, log [][]store.Redaction) {
+	if start < len(session.Chat) {
+		openaiclient.SetRedactions(session.Chat[start:], log)
+	}
+}
+
+// restoreNote puts the redacted values back into a generated note.
+func restoreNote(r *redact.Redactor, n *store.Note) {
+	n.Title = r.Restore(n.Title)
+	n.Body = r.Restore(n.Body)
+	for i := range n.ActionItems {
+		n.ActionItems[i].Text = r.Restore(n.ActionItems[i].Text)
+		n.ActionItems[i].Owner = r.Restore(n.ActionItems[i].Owner)
+	}
+	for i := range n.Decisions {
+		n.Decisions[i] = r.Restore(n.Decisions[i])
+	}
+	for i := range n.OpenQuestions {
+		n.OpenQuestions[i] = r.Restore(n.OpenQuestions[i])
+	}
+}
+
+// redactionSummary renders a message's redaction log for the chat view.
+func redactionSummary(log []store.Redaction) string {
+	placeholders := make([]string, len(log))
+	for i, l := range log {
+		placeholders[i] = l.Placeholder
+	}
+	return "redacted: " + strings.Join(placeholders, ", ")
+}
+
+// redactPreview describes what would be redacted from text, or from the
+// session as it would be sent if text is empty.
+func (m model) redactPreview(text string) string {
+	if m.rules == nil {
+		return "Redaction is disabled in the config."
+	}
+	var msgs []goopenai.ChatCompletionMessage
+	if text != "" {
+		msgs = append(msgs, goopenai.ChatCompletionMessage{Content: text})
+	} else {
+		for _, cm := range m.session.Chat {
+			if !openaiclient.Sent(cm) {
+				continue
+			}
+			msgs = append(msgs, goopenai.ChatCompletionMessage{Content: openaiclient.MessageText(cm)})
+		}
+	}
+	r := redact.New(m.rules)
+	var b strings.Builder
+	for i, msg := range msgs {
+		_, matches := r.Redact(msg.Content)
+		for _, match := range matches {
+			where := ""
+			if text == "" {
+				where = fmt.Sprintf("message %d: ", i+1)
+			}
+			// private keys span lines; their first line is enough
+			value, _, multiline := strings.Cut(match.Value, "\n")
+			if multiline {
+				value += "…"
+			}
+			fmt.Fprintf(&b, "\n- %s`%s` → %s", where, value, match.Placeholder)
+		}
+	}
+	if b.Len() == 0 {
+		return "Nothing would be redacted."
+	}
+	return "Would redact:" + b.String()
+}
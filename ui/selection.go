@@ -2,22 +2,284 @@ package ui
 
 import (
    "fmt"
+   "io"
+   "sort"
    "strings"
+   "time"
 
+   "github.com/charmbracelet/bubbles/key"
+   "github.com/charmbracelet/bubbles/list"
+   "github.com/charmbracelet/bubbles/textinput"
    tea "github.com/charmbracelet/bubbletea"
+   "github.com/charmbracelet/lipgloss"
+   "github.com/muesli/reflow/truncate"
+   "github.com/muesli/reflow/wordwrap"
+   "github.com/sergey-suslov/ai-notes/export"
    "github.com/sergey-suslov/ai-notes/store"
+   "github.com/sergey-suslov/ai-notes/util"
 )
 
+// session sort orders, cycled with s
+const (
+   sortCreated = iota
+   sortUpdated
+   sortMessages
+   sortCost
+)
+
+var sortNames = []string{"created", "last updated", "message count", "cost"}
+
+// previewMessages is how many trailing messages the preview pane shows.
+const previewMessages = 4
+
+var (
+   headerStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#105fa8"))
+   selectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#5fafff"))
+   dimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#808080"))
+   previewStyle  = lipgloss.NewStyle().Border(lipgloss.NormalBorder()).Padding(0, 1)
+)
+
+// sessionItem is a session in the picker with its number of notes; a nil
+// session is the "New Session" entry.
+type sessionItem struct {
+   s     *store.Session
+   notes int
+}
+
+// FilterValue matches on the title, description and message contents.
+func (i sessionItem) FilterValue() string {
+   if i.s == nil {
+       return "New Session"
+   }
+   var b strings.Builder
+   b.WriteString(i.s.DisplayTitle() + " " + i.s.Description)
+   for _, msg := range i.s.Chat {
+       b.WriteString(" " + msg.Content)
+   }
+   return b.String()
+}
+
+// headerItem is a date group heading such as "Today". It is skipped by the cursor.
+type headerItem string
+
+// FilterValue is empty so headers disappear while filtering.
+func (h headerItem) FilterValue() string { return "" }
+
+// sessionDelegate renders sessions and group headings in two lines each.
+type sessionDelegate struct{}
+
+func (d sessionDelegate) Height() int                             { return 2 }
+func (d sessionDelegate) Spacing() int                            { return 0 }
+func (d sessionDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+// Render writes one list entry.
+func (d sessionDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+   width := util.Max(0, m.Width()-4)
+   switch it := item.(type) {
+   case headerItem:
+       fmt.Fprintf(w, "\n%s", headerStyle.Render(string(it)))
+   case sessionItem:
+       cursor, style := "  ", lipgloss.NewStyle()
+       if index == m.Index() {
+           cursor, style = "> ", selectedStyle
+       }
+       if it.s == nil {
+           fmt.Fprintf(w, "%s\n%s", style.Render(cursor+"New Session"), dimStyle.Render("  start a new conversation"))
+           return
+       }
+       meta := fmt.Sprintf("%s · %d messages · $%.4f", it.s.LastUpdated().Format("2006-01-02 15:04"), len(it.s.Chat), it.s.Usage.Cost)
+       if it.notes > 0 {
+           meta += fmt.Sprintf(" · %d notes", it.notes)
+       }
+       if it.s.Description != "" {
+           meta += " · " + it.s.Description
+       }
+       fmt.Fprintf(w, "%s\n%s",
+           style.Render(cursor+truncate.StringWithTail(it.s.DisplayTitle(), uint(width), "…")),
+           dimStyle.Render("  "+truncate.StringWithTail(meta, uint(width), "…")))
+   }
+}
+
 // selectionModel handles choosing between new or existing sessions.
 type selectionModel struct {
    sessions        []*store.Session
-   cursor          int
+   list            list.Model
+   sortBy          int
    selectedSession *store.Session
+   // noteCounts is the number of notes per session ID
+   noteCounts map[string]int
+
+   // renaming the highlighted session, or exporting it when exporting is
+   // set; the input holds the title or the export format
+   renaming    bool
+   exporting   bool
+   renameInput textinput.Model
+   err         error
+
+   // confirming is the session awaiting delete confirmation
+   confirming *store.Session
+   // undo restores the last trashed session while its toast is visible
+   undo        *store.TrashItem
+   undoSession *store.Session
+   toast       toast
+   // openTrash asks the app to show the trash screen
+   openTrash bool
+   // openNotes asks the app to show the notes of a session
+   openNotes *store.Session
+
+   width, height int
 }
 
 // newSelectionModel constructs a selection model with existing sessions.
 func newSelectionModel(sessions []*store.Session) *selectionModel {
-   return &selectionModel{sessions: sessions}
+   l := list.New(nil, sessionDelegate{}, 0, 0)
+   l.DisableQuitKeybindings()
+   l.SetShowStatusBar(false)
+   // d deletes a session here, so it must not page
+   l.KeyMap.NextPage = key.NewBinding(
+       key.WithKeys("right", "l", "pgdown", "f"),
+       key.WithHelp("→/l/pgdn", "next page"),
+   )
+   l.AdditionalShortHelpKeys = func() []key.Binding {
+       return []key.Binding{
+           key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open")),
+           key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "rename")),
+           key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete")),
+           key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "notes")),
+           key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "export")),
+           key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort")),
+           key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "trash")),
+       }
+   }
+   ti := textinput.New()
+   ti.Prompt = "Title: "
+   m := &selectionModel{sessions: sessions, list: l, renameInput: ti}
+   m.refresh()
+   return m
+}
+
+// capturesEsc reports whether Esc is consumed by the picker itself
+// (cancelling a rename, a delete or a filter) rather than quitting.
+func (m *selectionModel) capturesEsc() bool {
+   return m.renaming || m.exporting || m.confirming != nil || m.list.FilterState() != list.Unfiltered
+}
+
+// setSessions replaces the listed sessions, e.g. after restoring from the trash.
+func (m *selectionModel) setSessions(sessions []*store.Session) {
+   m.sessions = sessions
+   m.refresh()
+   m.skipHeader(false)
+}
+
+// setNotes counts the notes of every session.
+func (m *selectionModel) setNotes(notes []*store.Note) {
+   m.noteCounts = make(map[string]int)
+   for _, n := range notes {
+       m.noteCounts[n.SessionID]++
+   }
+   m.refresh()
+   m.skipHeader(false)
+}
+
+// updateConfirm handles the delete confirmation prompt.
+func (m *selectionModel) updateConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
+   k, ok := msg.(tea.KeyMsg)
+   if !ok {
+       return m, nil
+   }
+   sess := m.confirming
+   m.confirming = nil
+   if k.String() != "y" {
+       return m, nil
+   }
+   item, err := sess.Trash()
+   if err != nil {
+       m.err = err
+       return m, nil
+   }
+   for i, s := range m.sessions {
+       if s == sess {
+           m.sessions = append(m.sessions[:i], m.sessions[i+1:]...)
+           break
+       }
+   }
+   m.refresh()
+   m.skipHeader(false)
+   m.undo, m.undoSession = item, sess
+   return m, m.toast.show(fmt.Sprintf("Moved %q to trash. Press u to undo.", item.Title))
+}
+
+// refresh rebuilds the list items in the current sort order, adding date
+// group headers when sorting by time.
+func (m *selectionModel) refresh() {
+   sessions := append([]*store.Session(nil), m.sessions...)
+   sort.SliceStable(sessions, func(i, j int) bool {
+       a, b := sessions[i], sessions[j]
+       switch m.sortBy {
+       case sortUpdated:
+           return a.LastUpdated().After(b.LastUpdated())
+       case sortMessages:
+           return len(a.Chat) > len(b.Chat)
+       case sortCost:
+           return a.Usage.Cost > b.Usage.Cost
+       default:
+           return a.CreatedAt.After(b.CreatedAt)
+       }
+   })
+   items := []list.Item{sessionItem{}}
+   group := ""
+   for _, s := range sessions {
+       if m.sortBy == sortCreated || m.sortBy == sortUpdated {
+           t := s.CreatedAt
+           if m.sortBy == sortUpdated {
+               t = s.LastUpdated()
+           }
+           if g := dateGroup(t, time.Now()); g != group {
+               group = g
+               items = append(items, headerItem(g))
+           }
+       }
+       items = append(items, sessionItem{s: s, notes: m.noteCounts[s.ID]})
+   }
+   m.list.SetItems(items)
+   m.list.Title = "Sessions (sorted by " + sortNames[m.sortBy] + ")"
+}
+
+// dateGroup names the period t falls into, relative to now.
+func dateGroup(t, now time.Time) string {
+   today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+   switch {
+   case !t.Before(today):
+       return "Today"
+   case !t.Before(today.AddDate(0, 0, -1)):
+       return "Yesterday"
+   case !t.Before(today.AddDate(0, 0, -7)):
+       return "Last week"
+   case !t.Before(today.AddDate(0, -1, 0)):
+       return "Last month"
+   default:
+       return "Older"
+   }
+}
+
+// current returns the highlighted session, or nil for "New Session" and headers.
+func (m *selectionModel) current() *store.Session {
+   if it, ok := m.list.SelectedItem().(sessionItem); ok {
+       return it.s
+   }
+   return nil
+}
+
+// skipHeader moves the cursor off a group header in the direction of travel.
+func (m *selectionModel) skipHeader(up bool) {
+   if _, ok := m.list.SelectedItem().(headerItem); !ok {
+       return
+   }
+   if up && m.list.Index() > 0 {
+       m.list.CursorUp()
+   } else {
+       m.list.CursorDown()
+   }
 }
 
 // Init does nothing.
@@ -25,68 +287,213 @@ func (m *selectionModel) Init() tea.Cmd {
    return nil
 }
 
-// Update handles up/down navigation and selection.
+// updateRename handles keys while the highlighted session is renamed.
+func (m *selectionModel) updateRename(msg tea.Msg) (tea.Model, tea.Cmd) {
+   if k, ok := msg.(tea.KeyMsg); ok {
+       switch k.Type {
+       case tea.KeyEnter:
+           sess := m.current()
+           old := sess.Title
+           sess.Title = strings.TrimSpace(m.renameInput.Value())
+           if err := sess.Save(); err != nil {
+               sess.Title = old
+               m.err = err
+           }
+           m.renaming = false
+           m.renameInput.Blur()
+           return m, nil
+       case tea.KeyEsc, tea.KeyCtrlC:
+           m.renaming = false
+           m.renameInput.Blur()
+           return m, nil
+       }
+   }
+   var cmd tea.Cmd
+   m.renameInput, cmd = m.renameInput.Update(msg)
+   return m, cmd
+}
+
+// updateExport handles keys while the export format of the highlighted
+// session is chosen.
+func (m *selectionModel) updateExport(msg tea.Msg) (tea.Model, tea.Cmd) {
+   if k, ok := msg.(tea.KeyMsg); ok {
+       switch k.Type {
+       case tea.KeyEnter:
+           m.exporting = false
+           m.renameInput.Blur()
+           f, err := export.Lookup(strings.TrimSpace(m.renameInput.Value()))
+           if err != nil {
+               m.err = err
+               return m, nil
+           }
+           dir, err := export.DefaultDir()
+           if err != nil {
+               m.err = err
+               return m, nil
+           }
+           paths, err := export.Sessions(dir, f, []*store.Session{m.current()})
+           if err != nil {
+               m.err = err
+               return m, nil
+           }
+           return m, m.toast.show("Exported to " + paths[0] + ".")
+       case tea.KeyEsc, tea.KeyCtrlC:
+           m.exporting = false
+           m.renameInput.Blur()
+           return m, nil
+       }
+   }
+   var cmd tea.Cmd
+   m.renameInput, cmd = m.renameInput.Update(msg)
+   return m, cmd
+}
+
+// Update handles list navigation, filtering and the session actions.
 func (m *selectionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+   if m.renaming {
+       return m.updateRename(msg)
+   }
+   if m.exporting {
+       return m.updateExport(msg)
+   }
+   if m.confirming != nil {
+       return m.updateConfirm(msg)
+   }
    switch msg := msg.(type) {
+   case toastExpiredMsg:
+       if m.toast.expire(msg) {
+           m.undo, m.undoSession = nil, nil
+       }
+       return m, nil
+   case tea.WindowSizeMsg:
+       m.width, m.height = msg.Width, msg.Height
+       m.list.SetSize(util.Max(30, msg.Width/2), util.Max(0, msg.Height-2))
+       return m, nil
    case tea.KeyMsg:
-       switch msg.Type {
-       case tea.KeyRunes:
-           // delete session
-           if len(msg.Runes) > 0 && msg.Runes[0] == 'd' {
-               // only delete existing sessions
-               if m.cursor > 0 && m.cursor <= len(m.sessions) {
-                   idx := m.cursor - 1
-                   sess := m.sessions[idx]
-                   // remove file
-                   _ = sess.Delete()
-                   // remove from list
-                   m.sessions = append(m.sessions[:idx], m.sessions[idx+1:]...)
-                   // adjust cursor
-                   if m.cursor > len(m.sessions) {
-                       m.cursor = len(m.sessions)
-                   }
-               }
+       // while typing a filter every key belongs to the list
+       if m.list.SettingFilter() {
+           break
+       }
+       switch msg.String() {
+       case "r":
+           if sess := m.current(); sess != nil {
+               m.renaming = true
+               m.err = nil
+               m.renameInput.Prompt = "Title: "
+               m.renameInput.SetValue(sess.Title)
+               m.renameInput.CursorEnd()
+               return m, m.renameInput.Focus()
+           }
+           return m, nil
+       case "d":
+           // only delete existing sessions, after confirmation
+           if sess := m.current(); sess != nil {
+               m.err = nil
+               m.confirming = sess
+           }
+           return m, nil
+       case "u":
+           if m.undo == nil {
+               return m, nil
+           }
+           if err := m.undo.Restore(); err != nil {
+               m.err = err
                return m, nil
            }
-       case tea.KeyUp:
-           if m.cursor > 0 {
-               m.cursor--
+           m.sessions = append(m.sessions, m.undoSession)
+           m.refresh()
+           m.undo, m.undoSession = nil, nil
+           return m, m.toast.show("Restored from trash.")
+       case "t":
+           m.openTrash = true
+           return m, nil
+       case "n":
+           if sess := m.current(); sess != nil {
+               m.openNotes = sess
            }
-       case tea.KeyDown:
-           if m.cursor < len(m.sessions) {
-               m.cursor++
+           return m, nil
+       case "x":
+           if m.current() != nil {
+               m.exporting = true
+               m.err = nil
+               m.renameInput.Prompt = "Export as (" + strings.Join(export.Names(), ", ") + "): "
+               m.renameInput.SetValue(export.DefaultFormat)
+               m.renameInput.CursorEnd()
+               return m, m.renameInput.Focus()
            }
-       case tea.KeyEnter:
-           if m.cursor == 0 {
+           return m, nil
+       case "s":
+           m.sortBy = (m.sortBy + 1) % len(sortNames)
+           m.refresh()
+           return m, nil
+       case "enter":
+           if _, ok := m.list.SelectedItem().(sessionItem); !ok {
+               return m, nil
+           }
+           if sess := m.current(); sess != nil {
+               // Resume existing session
+               m.selectedSession = sess
+           } else {
                // New session
                m.selectedSession = store.NewSession()
-           } else {
-               // Resume existing session
-               m.selectedSession = m.sessions[m.cursor-1]
            }
            return m, nil
        }
    }
-   return m, nil
+   var cmd tea.Cmd
+   m.list, cmd = m.list.Update(msg)
+   up := false
+   if k, ok := msg.(tea.KeyMsg); ok {
+       up = key.Matches(k, m.list.KeyMap.CursorUp, m.list.KeyMap.PrevPage, m.list.KeyMap.GoToStart)
+   }
+   m.skipHeader(up)
+   return m, cmd
 }
 
-// View renders the menu of sessions.
+// View renders the session list next to a preview of the highlighted session.
 func (m *selectionModel) View() string {
+   left := m.list.View()
+   if m.renaming || m.exporting {
+       left += "\n" + m.renameInput.View()
+   }
+   if m.confirming != nil {
+       left += fmt.Sprintf("\nMove %q to trash? (y/n)", m.confirming.DisplayTitle())
+   }
+   if m.toast.text != "" {
+       left += "\n" + m.toast.text
+   }
+   if m.err != nil {
+       left += "\nError: " + m.err.Error()
+   }
+   previewWidth := m.width - m.list.Width() - 4
+   if previewWidth < 20 {
+       return left
+   }
+   return lipgloss.JoinHorizontal(lipgloss.Top, left, previewStyle.Width(previewWidth).MaxHeight(util.Max(0, m.height-2)).Render(m.preview(previewWidth)))
+}
+
+// preview renders the last few messages of the highlighted session.
+func (m *selectionModel) preview(width int) string {
+   sess := m.current()
+   if sess == nil {
+       return dimStyle.Render("Start a new conversation.")
+   }
    var b strings.Builder
-   b.WriteString("Select a session (↑/↓, Enter to select, d to delete, esc to cancel):\n\n")
-   // Option 0: new session
-   cursor := " "
-   if m.cursor == 0 {
-       cursor = ">"
-   }
-   b.WriteString(fmt.Sprintf("%s New Session\n", cursor))
-   // Existing sessions
-   for i, s := range m.sessions {
-       prefix := " "
-       if m.cursor == i+1 {
-           prefix = ">"
+   b.WriteString(headerStyle.Render(sess.DisplayTitle()) + "\n")
+   if sess.Description != "" {
+       b.WriteString(dimStyle.Render(wordwrap.String(sess.Description, width)) + "\n")
+   }
+   chat := sess.Chat
+   if len(chat) > previewMessages {
+       chat = chat[len(chat)-previewMessages:]
+   }
+   for _, msg := range chat {
+       who := "AI"
+       if msg.Role == "user" {
+           who = "You"
        }
-       b.WriteString(fmt.Sprintf("%s %s (%s)\n", prefix, s.ID, s.CreatedAt.Format("2006-01-02 15:04:05")))
+       content := truncate.StringWithTail(strings.Join(strings.Fields(msg.Content), " "), 300, "…")
+       b.WriteString("\n" + headerStyle.Render(who+":") + " " + wordwrap.String(content, width) + "\n")
    }
    return b.String()
-}
\ No newline at end of file
+}
@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// tagsModel lists every tag with its note count so the notes browser can be filtered.
+type tagsModel struct {
+	tags     []string
+	counts   map[string]int
+	cursor   int
+	selected *string // chosen tag, "" for all notes
+}
+
+// newTagsModel collects the tags used by notes.
+func newTagsModel(notes []*store.Note) *tagsModel {
+	counts := make(map[string]int)
+	for _, n := range notes {
+		for _, t := range n.Tags {
+			counts[t]++
+		}
+	}
+	// entry 0 shows all notes
+	tags := append([]string{""}, store.TagVocabulary(notes)...)
+	return &tagsModel{tags: tags, counts: counts}
+}
+
+// Init is required by Bubble Tea; no initial command.
+func (m *tagsModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles navigation and selection.
+func (m *tagsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyUp:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case tea.KeyDown:
+			if m.cursor < len(m.tags)-1 {
+				m.cursor++
+			}
+		case tea.KeyEnter:
+			tag := m.tags[m.cursor]
+			m.selected = &tag
+		}
+	}
+	return m, nil
+}
+
+// View renders the tag list.
+func (m *tagsModel) View() string {
+	var b strings.Builder
+	b.WriteString("Filter notes by tag (↑/↓, Enter to select, esc to go back):\n\n")
+	for i, t := range m.tags {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		if t == "" {
+			b.WriteString(fmt.Sprintf("%s All notes\n", cursor))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%s #%s (%d)\n", cursor, t, m.counts[t]))
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// noteMode selects which messages a generated note covers.
+type noteMode int
+
+const (
+	noteFull   noteMode = iota // summarize the whole session into a new note
+	noteDelta                  // summarize only messages since the last note
+	noteLiving                 // merge new messages into the session's living note
+)
+
+// noteRequest describes a note to generate, as chosen in the template picker.
+type noteRequest struct {
+	tpl        *store.Template
+	mode       noteMode
+	structured bool // extract title, tags, action items, decisions and open questions as JSON
+}
+
+// templatesModel lets the user pick the note template used by Ctrl+N.
+type templatesModel struct {
+	templates  []*store.Template
+	cursor     int
+	structured bool
+	selected   *noteRequest
+}
+
+// newTemplatesModel constructs a templatesModel, preselecting the default template.
+func newTemplatesModel(templates []*store.Template) *templatesModel {
+	m := &templatesModel{templates: templates}
+	for i, t := range templates {
+		if t.Name == store.DefaultTemplate {
+			m.cursor = i
+		}
+	}
+	return m
+}
+
+// Init is required by Bubble Tea; no initial command.
+func (m *templatesModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles navigation and selection.
+func (m *templatesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyUp:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case tea.KeyDown:
+			if m.cursor < len(m.templates)-1 {
+				m.cursor++
+			}
+		case tea.KeyEnter:
+			m.choose(noteFull)
+		case tea.KeyRunes:
+			switch msg.String() {
+			case "d":
+				m.choose(noteDelta)
+			case "u":
+				m.choose(noteLiving)
+			case "s":
+				m.structured = !m.structured
+			}
+		}
+	}
+	return m, nil
+}
+
+// choose selects the highlighted template with the given mode.
+func (m *templatesModel) choose(mode noteMode) {
+	if len(m.templates) == 0 {
+		return
+	}
+	m.selected = &noteRequest{tpl: m.templates[m.cursor], mode: mode, structured: m.structured}
+}
+
+// View renders the list of templates.
+func (m *templatesModel) View() string {
+	var b strings.Builder
+	b.WriteString("Select a note template (↑/↓, Enter for a full note, d for new messages only, u to update the living note, s to toggle structured output, esc to cancel):\n")
+	structured := "off"
+	if m.structured {
+		structured = "on"
+	}
+	b.WriteString("Structured output: " + structured + "\n\n")
+	for i, t := range m.templates {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		b.WriteString(fmt.Sprintf("%s %s - %s\n", cursor, t.Name, t.Description))
+	}
+	return b.String()
+}
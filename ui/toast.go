@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// toastDuration is how long a toast, and the undo it offers, stays visible.
+const toastDuration = 5 * time.Second
+
+// toast is a transient status line shown below a list.
+type toast struct {
+	text string
+	seq  int
+}
+
+// toastExpiredMsg hides the toast it was scheduled for.
+type toastExpiredMsg struct{ seq int }
+
+// show displays text and returns a command that hides it after toastDuration.
+func (t *toast) show(text string) tea.Cmd {
+	t.seq++
+	t.text = text
+	seq := t.seq
+	return tea.Tick(toastDuration, func(time.Time) tea.Msg { return toastExpiredMsg{seq: seq} })
+}
+
+// expire hides the toast if msg belongs to it and reports whether it did.
+func (t *toast) expire(msg toastExpiredMsg) bool {
+	if msg.seq != t.seq {
+		return false
+	}
+	t.text = ""
+	return true
+}
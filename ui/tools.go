@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/truncate"
+	"github.com/muesli/reflow/wordwrap"
+
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// maxToolRounds is how many replies in a row may call tools before the
+// model has to answer with what it has found.
+const maxToolRounds = 8
+
+// nextTool runs the next queued tool call, or asks the user first if it has
+// side effects. Once the queue is empty the results go back to the model.
+func (m model) nextTool() (model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch {
+	case len(m.queue) == 0:
+		m.toolRounds++
+		cmd = m.getCompletionCmd()
+	case m.toolbox.SideEffect(m.queue[0].Name):
+		m.confirming = true
+	default:
+		cmd = m.runToolCmd(m.queue[0])
+	}
+	m.viewport.SetContent(m.getChatString())
+	m.viewport.GotoBottom()
+	return m, cmd
+}
+
+// runToolCmd builds a tea.Cmd that runs call.
+func (m model) runToolCmd(call store.ToolCall) tea.Cmd {
+	return func() tea.Msg {
+		result, err := m.toolbox.Run(m.session, call)
+		return toolResultMsg{call: call, result: result, err: err}
+	}
+}
+
+// toolResult records the result of the first queued call and moves on to
+// the next one.
+func (m model) toolResult(msg toolResultMsg) (model, tea.Cmd) {
+	content := msg.result
+	if msg.err != nil {
+		content = "Error: " + msg.err.Error()
+	}
+	m.session.Chat = append(m.session.Chat, store.Message{
+		Role:       store.RoleTool,
+		Content:    content,
+		ToolCallID: msg.call.ID,
+		Name:       msg.call.Name,
+	})
+	if len(m.queue) > 0 {
+		m.queue = m.queue[1:]
+	}
+	return m.nextTool()
+}
+
+// cancelTools drops the queued tool calls and ends the reply there. Calls
+// left without a result are sent to the model as plain text later on.
+func (m model) cancelTools() (model, tea.Cmd) {
+	m.confirming = false
+	m.queue = nil
+	m.toolRounds = 0
+	m.session.Chat = append(m.session.Chat, store.Message{Role: store.RoleStatus, Content: "Tool calls cancelled."})
+	m.viewport.SetContent(m.getChatString())
+	m.viewport.GotoBottom()
+	return m, nil
+}
+
+// renderToolCall renders a tool call on one line, or with its arguments in
+// full if tool calls are expanded.
+func (m model) renderToolCall(call store.ToolCall, width int) string {
+	if !m.expandTools {
+		line := "▸ " + call.Name + " " + strings.Join(strings.Fields(call.Arguments), " ")
+		return dimStyle.Render("  " + truncate.StringWithTail(line, uint(width), "…"))
+	}
+	return dimStyle.Render("  ▾ "+call.Name) + "\n" + indent(wordwrap.String(prettyArgs(call.Arguments), width-4), "    ")
+}
+
+// renderToolResult renders the result of a tool call on one line, or in
+// full if tool calls are expanded.
+func (m model) renderToolResult(msg store.Message, width int) string {
+	lines := strings.Count(strings.TrimRight(msg.Content, "\n"), "\n") + 1
+	if !m.expandTools {
+		first, _, _ := strings.Cut(strings.TrimSpace(msg.Content), "\n")
+		line := fmt.Sprintf("↳ %s (%d lines, Ctrl+T to expand)", first, lines)
+		return dimStyle.Render("    " + truncate.StringWithTail(line, uint(width), "…"))
+	}
+	return dimStyle.Render("    ↳ result of "+msg.Name) + "\n" + indent(wordwrap.String(msg.Content, width-6), "      ")
+}
+
+// confirmPrompt asks whether to run the first queued call.
+func (m model) confirmPrompt(width int) string {
+	call := m.queue[0]
+	return headerStyle.Render(fmt.Sprintf("The assistant wants to run %s:", call.Name)) + "\n" +
+		indent(wordwrap.String(prettyArgs(call.Arguments), width-4), "    ") + "\n" +
+		headerStyle.Render("Allow it? (y/n, Esc to stop the reply)")
+}
+
+// prettyArgs indents the JSON arguments of a call, or returns them as they
+// are if they are not valid JSON.
+func prettyArgs(args string) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(args), "", "  "); err != nil {
+		return args
+	}
+	return b.String()
+}
+
+// indent prefixes every line of s.
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
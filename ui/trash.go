@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sergey-suslov/ai-notes/store"
+)
+
+// trashModel lists trashed sessions and notes for restoring or purging.
+type trashModel struct {
+	items   []*store.TrashItem
+	cursor  int
+	purging bool // waiting for purge confirmation
+	toast   toast
+	err     error
+}
+
+// newTrashModel constructs a trashModel from the trashed items.
+func newTrashModel(items []*store.TrashItem) *trashModel {
+	return &trashModel{items: items}
+}
+
+// Init is required by Bubble Tea; no initial command.
+func (m *trashModel) Init() tea.Cmd {
+	return nil
+}
+
+// remove drops the highlighted item from the list.
+func (m *trashModel) remove() {
+	m.items = append(m.items[:m.cursor], m.items[m.cursor+1:]...)
+	if m.cursor > 0 && m.cursor >= len(m.items) {
+		m.cursor--
+	}
+}
+
+// Update handles navigation, restoring and purging.
+func (m *trashModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case toastExpiredMsg:
+		m.toast.expire(msg)
+	case tea.KeyMsg:
+		if m.purging {
+			m.purging = false
+			if msg.String() != "y" {
+				return m, nil
+			}
+			item := m.items[m.cursor]
+			if err := item.Purge(); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.remove()
+			return m, m.toast.show(fmt.Sprintf("Permanently deleted %q.", item.Title))
+		}
+		switch msg.Type {
+		case tea.KeyUp:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case tea.KeyDown:
+			if m.cursor < len(m.items)-1 {
+				m.cursor++
+			}
+		case tea.KeyRunes:
+			if len(m.items) == 0 {
+				return m, nil
+			}
+			switch msg.String() {
+			case "r":
+				item := m.items[m.cursor]
+				if err := item.Restore(); err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.err = nil
+				m.remove()
+				return m, m.toast.show(fmt.Sprintf("Restored %q.", item.Title))
+			case "p":
+				m.err = nil
+				m.purging = true
+			}
+		}
+	}
+	return m, nil
+}
+
+// View renders the trashed items.
+func (m *trashModel) View() string {
+	var b strings.Builder
+	b.WriteString("Trash (↑/↓, r to restore, p to delete permanently, esc to go back):\n\n")
+	if len(m.items) == 0 {
+		b.WriteString("  The trash is empty.\n")
+	}
+	for i, item := range m.items {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		b.WriteString(fmt.Sprintf("%s [%s] %s (deleted %s)\n", cursor, item.Kind, item.Title, item.DeletedAt.Format("2006-01-02 15:04:05")))
+	}
+	if m.purging {
+		b.WriteString(fmt.Sprintf("\nPermanently delete %q? (y/n)\n", m.items[m.cursor].Title))
+	}
+	if m.toast.text != "" {
+		b.WriteString("\n" + m.toast.text + "\n")
+	}
+	if m.err != nil {
+		b.WriteString("\nError: " + m.err.Error() + "\n")
+	}
+	return b.String()
+}
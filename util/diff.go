@@ -0,0 +1,59 @@
+package util
+
+import "strings"
+
+// Diff line kinds.
+const (
+	DiffSame    = ' '
+	DiffAdded   = '+'
+	DiffRemoved = '-'
+)
+
+// DiffLine is one line of a line diff.
+type DiffLine struct {
+	Kind byte // DiffSame, DiffAdded or DiffRemoved
+	Text string
+}
+
+// LineDiff returns the lines of a and b as a diff turning a into b, based on
+// their longest common subsequence of lines.
+func LineDiff(a, b string) []DiffLine {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = Max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []DiffLine
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, DiffLine{DiffSame, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, DiffLine{DiffRemoved, x[i]})
+			i++
+		default:
+			out = append(out, DiffLine{DiffAdded, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, DiffLine{DiffRemoved, x[i]})
+	}
+	for ; j < len(y); j++ {
+		out = append(out, DiffLine{DiffAdded, y[j]})
+	}
+	return out
+}
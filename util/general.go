@@ -1,5 +1,10 @@
 package util
 
+import (
+	"strings"
+	"unicode/utf8"
+)
+
 func Max(a, b int) int {
 	if a > b {
 		return a
@@ -15,3 +20,44 @@ func Min(a, b int) int {
 
 	return b
 }
+
+// EstimateTokens returns a rough token count for s, using the common
+// approximation of four characters per token.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// SplitTokens breaks s into chunks of at most maxTokens (as estimated by
+// EstimateTokens), preferring line boundaries.
+func SplitTokens(s string, maxTokens int) []string {
+	if maxTokens <= 0 || EstimateTokens(s) <= maxTokens {
+		return []string{s}
+	}
+	maxBytes := maxTokens * 4
+	var chunks []string
+	var cur strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		for len(line) > maxBytes {
+			// a single overlong line: cut it on a rune boundary
+			cut := maxBytes
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cur.Len() > 0 {
+				chunks = append(chunks, cur.String())
+				cur.Reset()
+			}
+			chunks = append(chunks, line[:cut])
+			line = line[cut:]
+		}
+		if cur.Len()+len(line) > maxBytes {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(line)
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
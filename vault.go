@@ -0,0 +1,92 @@
+package main
+
+import (
+   "errors"
+   "flag"
+   "fmt"
+   "path/filepath"
+
+   "github.com/sergey-suslov/ai-notes/config"
+   "github.com/sergey-suslov/ai-notes/importer"
+   "github.com/sergey-suslov/ai-notes/store"
+)
+
+// runVault implements "ai-notes vault": it indexes markdown vaults in place
+// (add, remove, list) or copies their notes into ai-notes (import).
+func runVault(args []string) error {
+   fs := flag.NewFlagSet("vault", flag.ContinueOnError)
+   dryRun := fs.Bool("n", false, "import: only report what would be imported")
+   fs.Usage = func() {
+       fmt.Fprintf(fs.Output(), "usage: ai-notes vault add|remove|import DIR\n       ai-notes vault list\n\n")
+       fs.PrintDefaults()
+   }
+   // the command comes first: "vault import -n DIR"
+   cmd := ""
+   if len(args) > 0 {
+       cmd, args = args[0], args[1:]
+   }
+   if err := fs.Parse(args); err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           return nil
+       }
+       return err
+   }
+   cfg, err := config.Load()
+   if err != nil {
+       return fmt.Errorf("loading config: %w", err)
+   }
+   if cmd == "list" {
+       for _, dir := range cfg.Vaults {
+           fmt.Println(dir)
+       }
+       return nil
+   }
+   if fs.NArg() != 1 {
+       fs.Usage()
+       return fmt.Errorf("expected a command and a directory")
+   }
+   dir, err := filepath.Abs(fs.Arg(0))
+   if err != nil {
+       return err
+   }
+   switch cmd {
+   case "add":
+       for _, v := range cfg.Vaults {
+           if v == dir {
+               return fmt.Errorf("%s is already indexed", dir)
+           }
+       }
+       // fail early on unreadable vaults
+       notes, err := store.LoadVault(dir)
+       if err != nil {
+           return err
+       }
+       cfg.Vaults = append(cfg.Vaults, dir)
+       if err := cfg.Save(); err != nil {
+           return err
+       }
+       fmt.Printf("indexed %d notes from %s\n", len(notes), dir)
+   case "remove":
+       var kept []string
+       for _, v := range cfg.Vaults {
+           if v != dir {
+               kept = append(kept, v)
+           }
+       }
+       if len(kept) == len(cfg.Vaults) {
+           return fmt.Errorf("%s is not indexed", dir)
+       }
+       cfg.Vaults = kept
+       return cfg.Save()
+   case "import":
+       report, err := importer.Vault(dir, importer.Options{DryRun: *dryRun})
+       if report != nil {
+           fmt.Print(report)
+       }
+       return err
+   default:
+       fs.Usage()
+       return fmt.Errorf("unknown vault command %q", cmd)
+   }
+   return nil
+}